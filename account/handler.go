@@ -2,14 +2,12 @@ package account
 
 import (
 	"context"
-	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/a-h/templ"
+	"github.com/josuebrunel/sportdropin/pkg/collection"
 	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
 	"github.com/josuebrunel/sportdropin/pkg/models"
-	"github.com/josuebrunel/sportdropin/pkg/pbclient"
 	pb "github.com/josuebrunel/sportdropin/pkg/pbclient"
 	"github.com/josuebrunel/sportdropin/pkg/view"
 	"github.com/josuebrunel/sportdropin/pkg/view/component"
@@ -36,25 +34,43 @@ type AccountHandler struct {
 	Collection string
 	pathParam  string
 	api        *pb.Client
+	// baseURL is the public url of the app, used to build the urls given
+	// to third parties such as the OAuth2 redirect url
+	baseURL string
 }
 
 func NewAccountHandler(baseURL string) AccountHandler {
 	api := pb.New(baseURL)
-	return AccountHandler{Collection: "users", pathParam: "accountid", api: &api}
+	return AccountHandler{Collection: "users", pathParam: "accountid", api: &api, baseURL: baseURL}
 }
 
 func (a AccountHandler) GetSession(cx context.Context) xsession.XUser {
 	return xsession.GetUser(cx)
 }
 
+// GetClient returns an api client authenticated with the session user.
+func (a AccountHandler) GetClient(cx context.Context) *pb.Client {
+	api := a.api.WithAuth(xsession.AuthStore(cx))
+	return &api
+}
+
+// renderLogin renders the login form along with the enabled OAuth2 providers.
+func (a AccountHandler) renderLogin(c echo.Context, em errorsmap.EMap) error {
+	methods, err := a.api.AuthMethodsCtx(c.Request().Context())
+	if err != nil {
+		xlog.Error("error while getting auth methods", "error", err)
+	}
+	return view.Render(c, http.StatusOK, LoginFormView(em,
+		templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.login")},
+		methods.AuthProviders),
+		nil,
+	)
+}
+
 func (a AccountHandler) Login(cx context.Context) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		if c.Request().Method == http.MethodGet {
-			return view.Render(c, http.StatusOK, LoginFormView(
-				errorsmap.New(),
-				templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.login")}),
-				nil,
-			)
+			return a.renderLogin(c, errorsmap.New())
 		}
 		var (
 			req RequestLoginForm
@@ -63,22 +79,11 @@ func (a AccountHandler) Login(cx context.Context) echo.HandlerFunc {
 		if err = c.Bind(&req); err != nil {
 			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		resp, err := a.api.UserAuth(req.Username, req.Password)
+		resp, err := a.GetClient(c.Request().Context()).UserAuthCtx(c.Request().Context(), req.Username, req.Password)
 		if err != nil {
-			em := errorsmap.New()
-			data := pb.ResponseTo[pb.ResponseError](resp)
-			if strings.EqualFold(data.Message, "") {
-				em["error"] = err
-			} else {
-				em["error"] = errors.New(data.Message)
-			}
-			return view.Render(c, http.StatusOK, LoginFormView(em,
-				templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.login")}),
-				nil,
-			)
+			return a.renderLogin(c, pb.ErrorsMap(err))
 		}
 		user := pb.ResponseTo[pb.ResponseAuth](resp)
-		xsession.SetUser(c.Request().Context(), xsession.XUser{ID: user.Record.ID, Token: user.Token, Email: user.Record.Email})
 		return c.Redirect(http.StatusFound, view.ReverseX(c, "account.get", user.Record.ID))
 	}
 }
@@ -93,13 +98,13 @@ func (a AccountHandler) Logout(cx context.Context) echo.HandlerFunc {
 func (a AccountHandler) Get(cx context.Context) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		sess := a.GetSession(c.Request().Context())
+		api := a.GetClient(c.Request().Context())
 		id := c.PathParam(a.pathParam)
 		xlog.Debug("user info", "id", id, "session", sess)
-		resp, err := a.api.RecordGet("users", id, pb.QHeaders{"Authorization": sess.Token})
+		user, err := pb.Collection[UserModel](api, a.Collection).WithContext(c.Request().Context()).Get(id)
 		if err != nil {
 			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		user := pb.ResponseTo[UserModel](resp)
 		return view.Render(c, http.StatusOK, ProfileView(user), nil)
 	}
 }
@@ -126,9 +131,9 @@ func (a AccountHandler) Create(cx context.Context) echo.HandlerFunc {
 				nil,
 			)
 		}
-		resp, err := a.api.RecordCreate("users", pb.NewQData(req))
+		_, err = pb.Collection[UserModel](a.api, a.Collection).WithContext(c.Request().Context()).Create(req)
 		if err != nil {
-			em["error"] = err
+			em = pb.ErrorsMap(err)
 			return view.Render(c, http.StatusOK, RegisterFormView(
 				em,
 				templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.register")}),
@@ -136,7 +141,6 @@ func (a AccountHandler) Create(cx context.Context) echo.HandlerFunc {
 			)
 
 		}
-		_ = pbclient.ResponseTo[pbclient.UserRecord](resp)
 		return c.Redirect(http.StatusFound, view.ReverseX(c, "account.login"))
 	}
 }
@@ -144,19 +148,17 @@ func (a AccountHandler) Create(cx context.Context) echo.HandlerFunc {
 func (a AccountHandler) Update(cx context.Context) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var (
-			id   = c.PathParam(a.pathParam)
-			em   = errorsmap.New()
-			sess = a.GetSession(c.Request().Context())
-			user = UserModel{}
+			id    = c.PathParam(a.pathParam)
+			em    = errorsmap.New()
+			users = pb.Collection[UserModel](a.GetClient(c.Request().Context()), a.Collection).WithContext(c.Request().Context())
 		)
-		resp, err := a.api.RecordGet("users", id, pb.QHeaders{"Authorization": sess.Token})
+		user, err := users.Get(id)
 		if err != nil {
 			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		user = pb.ResponseTo[UserModel](resp)
 		if c.Request().Method == http.MethodGet {
 			return view.Render(c, http.StatusOK, EditFormView(user, em,
-				templ.Attributes{"hx-patch": view.ReverseX(c, "account.update", id), "hx-target": "#content"}),
+				templ.Attributes{"hx-patch": view.ReverseX(c, "account.update", id), "hx-target": "#content", "hx-encoding": "multipart/form-data"}),
 				nil,
 			)
 		}
@@ -164,38 +166,55 @@ func (a AccountHandler) Update(cx context.Context) echo.HandlerFunc {
 		if err = c.Bind(&req); err != nil {
 			em["error"] = err
 			return view.Render(c, http.StatusOK, EditFormView(user, em,
-				templ.Attributes{"hx-patch": view.ReverseX(c, "account.update", id), "hx-target": "#content"}),
+				templ.Attributes{"hx-patch": view.ReverseX(c, "account.update", id), "hx-target": "#content", "hx-encoding": "multipart/form-data"}),
 				nil,
 			)
 		}
-		_, err = a.api.RecordUpdate("users", id, pb.NewQData(req), pb.QHeaders{"Authorization": sess.Token})
+		data, err := withAvatar(c, req)
 		if err != nil {
-			em["error"] = err
+			em["avatar"] = err
 			return view.Render(c, http.StatusOK, EditFormView(user, em,
-				templ.Attributes{"hx-patch": view.ReverseX(c, "account.update", id), "hx-target": "#content"}),
+				templ.Attributes{"hx-patch": view.ReverseX(c, "account.update", id), "hx-target": "#content", "hx-encoding": "multipart/form-data"}),
 				nil,
 			)
 		}
-		resp, err = a.api.RecordGet("users", id, pb.QHeaders{"Authorization": sess.Token})
+		updated, err := users.Update(id, data)
 		if err != nil {
-			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
+			em = pb.ErrorsMap(err)
+			return view.Render(c, http.StatusOK, EditFormView(user, em,
+				templ.Attributes{"hx-patch": view.ReverseX(c, "account.update", id), "hx-target": "#content", "hx-encoding": "multipart/form-data"}),
+				nil,
+			)
 		}
-		user = pb.ResponseTo[UserModel](resp)
-		return view.Render(c, http.StatusOK, ProfileView(user), nil)
+		return view.Render(c, http.StatusOK, ProfileView(updated), nil)
 	}
 }
 
 func (a AccountHandler) Groups(cx context.Context) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		var (
-			id   = c.PathParam(a.pathParam)
-			sess = a.GetSession(c.Request().Context())
+			id  = c.PathParam(a.pathParam)
+			api = a.GetClient(c.Request().Context())
 		)
-		resp, err := a.api.RecordGet("users", id, pb.QHeaders{"Authorization": sess.Token}, pb.QExpand{"groups_via_user.sport"})
+		user, err := pb.Collection[models.UserExpandGroup](api, a.Collection).WithContext(c.Request().Context()).Get(id, pb.QExpand{"groups_via_user.sport"})
 		if err != nil {
 			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		user := pb.ResponseTo[models.UserExpandGroup](resp)
-		return view.Render(c, http.StatusOK, GroupListView(user.Expand.Groups, templ.Attributes{}), nil)
+		groups := collection.Filter(user.Expand.Groups, func(g models.Group) bool { return g.Deleted == "" })
+		return view.Render(c, http.StatusOK, GroupListView(groups, templ.Attributes{}), nil)
+	}
+}
+
+// withAvatar returns the payload to send for req. It's a multipart payload
+// when an avatar was uploaded along with the form.
+func withAvatar(c echo.Context, req any) (any, error) {
+	fh, err := c.FormFile("avatar")
+	if err != nil {
+		return req, nil
+	}
+	file, err := pb.NewQFileHeader("avatar", fh)
+	if err != nil {
+		return nil, err
 	}
+	return pb.NewQMultipart(req, file)
 }
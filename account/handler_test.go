@@ -0,0 +1,171 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/josuebrunel/sportdropin/pkg/pbclient/pbtest"
+	"github.com/josuebrunel/sportdropin/pkg/xsession"
+	"github.com/labstack/echo/v5"
+)
+
+// testApp serves the account routes against a fake PocketBase.
+type testApp struct {
+	pb     *pbtest.Server
+	web    *httptest.Server
+	client *http.Client
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	pb := pbtest.NewServer()
+	t.Cleanup(pb.Close)
+	web := httptest.NewUnstartedServer(nil)
+	t.Cleanup(web.Close)
+	h := NewAccountHandler(pb.URL)
+	h.api.Retry.MaxRetries = 0
+	h.baseURL = "http://" + web.Listener.Addr().String()
+	ctx := context.Background()
+
+	e := echo.New()
+	e.Use(xsession.LoadAndSave(xsession.SessionManager))
+	a := e.Group("/account")
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/login", Handler: h.Login(ctx), Name: "account.login"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/login", Handler: h.Login(ctx), Name: "account.login"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/logout", Handler: h.Logout(ctx), Name: "account.logout"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/oauth/:provider", Handler: h.OAuth2(ctx), Name: "account.oauth"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/oauth/:provider/callback", Handler: h.OAuth2Callback(ctx), Name: "account.oauth.callback"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid", Handler: h.Get(ctx), Name: "account.get",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/register", Handler: h.Create(ctx), Name: "account.register"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/register", Handler: h.Create(ctx), Name: "account.register"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid/edit", Handler: h.Update(ctx), Name: "account.update",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:accountid/edit", Handler: h.Update(ctx), Name: "account.update",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/password-reset", Handler: h.PasswordReset(ctx), Name: "account.password-reset"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/password-reset", Handler: h.PasswordReset(ctx), Name: "account.password-reset"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/password-reset/:token", Handler: h.PasswordResetConfirm(ctx), Name: "account.password-reset.confirm"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/password-reset/:token", Handler: h.PasswordResetConfirm(ctx), Name: "account.password-reset.confirm"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/verify", Handler: h.Verify(ctx), Name: "account.verify"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/verify", Handler: h.Verify(ctx), Name: "account.verify"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/verify/:token", Handler: h.VerifyConfirm(ctx), Name: "account.verify.confirm"})
+	web.Config.Handler = e
+	web.Start()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &testApp{pb: pb, web: web, client: client}
+}
+
+func (app *testApp) do(t *testing.T, method, path, contentType string, body io.Reader) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, app.web.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := app.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func (app *testApp) form(t *testing.T, method, path string, values url.Values) (*http.Response, string) {
+	t.Helper()
+	return app.do(t, method, path, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+}
+
+// login signs the user in and returns its id.
+func (app *testApp) login(t *testing.T, username, password string) string {
+	t.Helper()
+	resp, _ := app.form(t, http.MethodPost, "/account/login", url.Values{"username": {username}, "password": {password}})
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("login: got status %d, want a redirect", resp.StatusCode)
+	}
+	loc := resp.Header.Get("Location")
+	if !strings.HasPrefix(loc, "/account/") {
+		t.Fatalf("login: unexpected redirect to %q", loc)
+	}
+	return strings.TrimPrefix(loc, "/account/")
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	app := newTestApp(t)
+
+	resp, body := app.form(t, http.MethodPost, "/account/register", url.Values{
+		"email": {"joe@example.com"}, "username": {"joe"}, "password": {"secret123"}, "passwordConfirm": {"other"},
+	})
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "match") {
+		t.Fatalf("mismatching passwords: got %d %q", resp.StatusCode, body)
+	}
+	resp, _ = app.form(t, http.MethodPost, "/account/register", url.Values{
+		"email": {"joe@example.com"}, "username": {"joe"}, "password": {"secret123"}, "passwordConfirm": {"secret123"},
+	})
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/account/login" {
+		t.Fatalf("register: got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if users := app.pb.Records("users"); len(users) != 1 || users[0]["username"] != "joe" {
+		t.Fatalf("unexpected users %v", users)
+	}
+
+	resp, _ = app.form(t, http.MethodPost, "/account/login", url.Values{"username": {"joe"}, "password": {"wrong"}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong password: got status %d", resp.StatusCode)
+	}
+	id := app.login(t, "joe", "secret123")
+	resp, body = app.do(t, http.MethodGet, "/account/"+id, "", nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "joe@example.com") {
+		t.Fatalf("profile: got %d %q", resp.StatusCode, body)
+	}
+
+	app.do(t, http.MethodGet, "/account/logout", "", nil)
+	resp, _ = app.do(t, http.MethodGet, "/account/"+id, "", nil)
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("profile after logout: got status %d, want a redirect", resp.StatusCode)
+	}
+}
+
+func TestUpdateWithAvatar(t *testing.T) {
+	app := newTestApp(t)
+	app.pb.Seed("users", pbtest.Record{"username": "joe", "email": "joe@example.com", "password": "secret123"})
+	id := app.login(t, "joe", "secret123")
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.WriteField("username", "joe2")
+	w.WriteField("email", "joe@example.com")
+	part, _ := w.CreateFormFile("avatar", "me.png")
+	part.Write([]byte("png"))
+	w.Close()
+
+	resp, body := app.do(t, http.MethodPatch, "/account/"+id+"/edit", w.FormDataContentType(), &buf)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d: %s", resp.StatusCode, body)
+	}
+	users := app.pb.Records("users")
+	if users[0]["username"] != "joe2" || users[0]["avatar"] != "me.png" {
+		t.Fatalf("user not updated: %v", users[0])
+	}
+	if got := app.pb.LastRequest().Header.Get("Content-Type"); !strings.HasPrefix(got, "multipart/form-data") {
+		t.Errorf("update sent as %q, want multipart", got)
+	}
+}
@@ -0,0 +1,89 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	pb "github.com/josuebrunel/sportdropin/pkg/pbclient"
+	"github.com/josuebrunel/sportdropin/pkg/view"
+	"github.com/josuebrunel/sportdropin/pkg/view/component"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/josuebrunel/sportdropin/pkg/xsession"
+	"github.com/labstack/echo/v5"
+)
+
+const sessionOAuth2 = "oauth2"
+
+var ErrOAuth2State = errors.New("invalid or expired sign in attempt, please try again")
+
+// oauth2State is kept in session between the redirection to the provider
+// and the callback.
+type oauth2State struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	CodeVerifier string `json:"codeVerifier"`
+	RedirectURL  string `json:"redirectUrl"`
+}
+
+func (a AccountHandler) OAuth2(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name := c.PathParam("provider")
+		methods, err := a.api.AuthMethodsCtx(c.Request().Context())
+		if err != nil {
+			xlog.Error("error while getting auth methods", "error", err)
+			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
+		}
+		provider, ok := methods.Provider(name)
+		if !ok {
+			return view.Render(c, http.StatusNotFound, MessageView("Sign in", fmt.Sprintf("Sign in with %s isn't available.", name),
+				component.Link("Back to sign in", view.ReverseX(c, "account.login"), templ.Attributes{"role": "button"}),
+			), nil)
+		}
+		state := oauth2State{
+			Provider:     provider.Name,
+			State:        provider.State,
+			CodeVerifier: provider.CodeVerifier,
+			RedirectURL:  strings.TrimRight(a.baseURL, "/") + view.ReverseX(c, "account.oauth.callback", provider.Name),
+		}
+		b, _ := json.Marshal(state)
+		xsession.Set(c.Request().Context(), sessionOAuth2, b)
+		return c.Redirect(http.StatusFound, provider.RedirectURL(state.RedirectURL))
+	}
+}
+
+func (a AccountHandler) OAuth2Callback(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		var (
+			ctx   = c.Request().Context()
+			state oauth2State
+		)
+		json.Unmarshal(xsession.Get[[]byte](ctx, sessionOAuth2), &state)
+		xsession.Delete(ctx, sessionOAuth2)
+		if e := c.QueryParam("error"); e != "" {
+			xlog.Error("oauth2 provider returned an error", "provider", state.Provider, "error", e)
+			return a.renderLogin(c, errorsmap.EMap{"error": fmt.Errorf("sign in with %s failed: %s", c.PathParam("provider"), e)})
+		}
+		if state.State == "" || state.State != c.QueryParam("state") || state.Provider != c.PathParam("provider") {
+			xlog.Error("oauth2 state mismatch", "provider", c.PathParam("provider"))
+			return a.renderLogin(c, errorsmap.EMap{"error": ErrOAuth2State})
+		}
+		resp, err := a.GetClient(ctx).AuthWithOAuth2Ctx(ctx, pb.RequestOAuth2{
+			Provider:     state.Provider,
+			Code:         c.QueryParam("code"),
+			CodeVerifier: state.CodeVerifier,
+			RedirectURL:  state.RedirectURL,
+		})
+		if err != nil {
+			xlog.Error("error while authenticating with oauth2", "provider", state.Provider, "error", err)
+			return a.renderLogin(c, pb.ErrorsMap(err))
+		}
+		user := pb.ResponseTo[pb.ResponseAuth](resp)
+		return c.Redirect(http.StatusFound, view.ReverseX(c, "account.get", user.Record.ID))
+	}
+}
@@ -0,0 +1,99 @@
+package account
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/josuebrunel/sportdropin/pkg/pbclient/pbtest"
+)
+
+func TestOAuth2(t *testing.T) {
+	app := newTestApp(t)
+	app.pb.OAuth2Provider("google", pbtest.Record{"username": "ann", "email": "ann@example.com"})
+
+	// the Host header is forged, the redirect url must not follow it
+	req, _ := http.NewRequest(http.MethodGet, app.web.URL+"/account/oauth/google", nil)
+	req.Host = "evil.example.com"
+	resp, err := app.client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	// the jar filed the session cookie under the forged host
+	web, _ := url.Parse(app.web.URL)
+	app.client.Jar.SetCookies(web, resp.Cookies())
+	authorize, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("got %d to %q, want a redirect to the provider", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	callback := authorize.Query().Get("redirect_uri")
+	if want := app.web.URL + "/account/oauth/google/callback"; callback != want {
+		t.Fatalf("redirect_uri is %q, want %q", callback, want)
+	}
+
+	// the fake provider redirects back right away with a code
+	resp, err = app.client.Get(authorize.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	back := resp.Header.Get("Location")
+	if !strings.HasPrefix(back, callback+"?") {
+		t.Fatalf("provider redirected to %q", back)
+	}
+	resp, err = app.client.Get(back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	users := app.pb.Records("users")
+	if len(users) != 1 || users[0]["email"] != "ann@example.com" {
+		t.Fatalf("unexpected users %v", users)
+	}
+	if loc := resp.Header.Get("Location"); resp.StatusCode != http.StatusFound || loc != "/account/"+users[0]["id"].(string) {
+		t.Fatalf("callback: got %d to %q", resp.StatusCode, loc)
+	}
+	signIn := app.pb.RequestsTo(http.MethodPost, "/api/collections/users/auth-with-oauth2")
+	if len(signIn) != 1 || !strings.Contains(string(signIn[0].Body), callback) {
+		t.Fatalf("unexpected auth-with-oauth2 requests %v", signIn)
+	}
+	resp, body := app.do(t, http.MethodGet, "/account/"+users[0]["id"].(string), "", nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "ann@example.com") {
+		t.Fatalf("profile: got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestOAuth2Callback(t *testing.T) {
+	tests := []struct {
+		name  string
+		query url.Values
+	}{
+		{"no pending sign in", url.Values{"code": {"code"}, "state": {"state"}}},
+		{"provider error", url.Values{"error": {"access_denied"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t)
+			resp, body := app.do(t, http.MethodGet, "/account/oauth/google/callback?"+tt.query.Encode(), "", nil)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(body, "login") {
+				t.Fatalf("got %d %q, want the login form", resp.StatusCode, body)
+			}
+			if len(app.pb.RequestsTo(http.MethodPost, "/api/collections/users/auth-with-oauth2")) != 0 {
+				t.Fatal("signed in without a valid state")
+			}
+			if len(app.pb.Records("users")) != 0 {
+				t.Fatal("user created")
+			}
+		})
+	}
+}
+
+func TestOAuth2UnknownProvider(t *testing.T) {
+	app := newTestApp(t)
+	resp, _ := app.do(t, http.MethodGet, "/account/oauth/nope", "", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("got status %d, want 404", resp.StatusCode)
+	}
+}
@@ -0,0 +1,142 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	pb "github.com/josuebrunel/sportdropin/pkg/pbclient"
+	"github.com/josuebrunel/sportdropin/pkg/view"
+	"github.com/josuebrunel/sportdropin/pkg/view/component"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/josuebrunel/sportdropin/pkg/xsession"
+	"github.com/labstack/echo/v5"
+)
+
+func (a AccountHandler) PasswordReset(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		attr := templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.password-reset")}
+		if c.Request().Method == http.MethodGet {
+			return view.Render(c, http.StatusOK, PasswordResetFormView(errorsmap.New(), attr), nil)
+		}
+		var req pb.RequestEmail
+		if err := c.Bind(&req); err != nil {
+			return view.Render(c, http.StatusOK, PasswordResetFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		if _, err := a.api.RequestPasswordResetCtx(c.Request().Context(), req.Email); err != nil {
+			xlog.Error("error while requesting password reset", "email", req.Email, "error", err)
+			return view.Render(c, http.StatusOK, PasswordResetFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		return view.Render(c, http.StatusOK, MessageView(
+			"Password reset",
+			fmt.Sprintf("If an account is registered with %s, a link to reset its password has been sent to it.", req.Email),
+			nil,
+		), nil)
+	}
+}
+
+func (a AccountHandler) PasswordResetConfirm(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := c.PathParam("token")
+		attr := templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.password-reset.confirm", token)}
+		if c.Request().Method == http.MethodGet {
+			return view.Render(c, http.StatusOK, PasswordResetConfirmFormView(errorsmap.New(), attr), nil)
+		}
+		var req pb.RequestConfirmPasswordReset
+		if err := c.Bind(&req); err != nil {
+			return view.Render(c, http.StatusOK, PasswordResetConfirmFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		req.Token = token
+		if _, err := a.api.ConfirmPasswordResetCtx(c.Request().Context(), req); err != nil {
+			xlog.Error("error while confirming password reset", "error", err)
+			return view.Render(c, http.StatusOK, PasswordResetConfirmFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		return view.Render(c, http.StatusOK, MessageView("Password reset", "Your password has been changed.",
+			component.Link("Sign in", view.ReverseX(c, "account.login"), templ.Attributes{"role": "button"}),
+		), nil)
+	}
+}
+
+func (a AccountHandler) Verify(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		sess := a.GetSession(c.Request().Context())
+		attr := templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.verify")}
+		if c.Request().Method == http.MethodGet {
+			return view.Render(c, http.StatusOK, VerificationFormView(sess.Email, errorsmap.New(), attr), nil)
+		}
+		var req pb.RequestEmail
+		if err := c.Bind(&req); err != nil {
+			return view.Render(c, http.StatusOK, VerificationFormView(req.Email, pb.ErrorsMap(err), attr), nil)
+		}
+		if _, err := a.api.RequestVerificationCtx(c.Request().Context(), req.Email); err != nil {
+			xlog.Error("error while requesting verification", "email", req.Email, "error", err)
+			return view.Render(c, http.StatusOK, VerificationFormView(req.Email, pb.ErrorsMap(err), attr), nil)
+		}
+		return view.Render(c, http.StatusOK, MessageView(
+			"Email verification",
+			fmt.Sprintf("If %s is registered and not yet verified, a verification link has been sent to it.", req.Email),
+			nil,
+		), nil)
+	}
+}
+
+func (a AccountHandler) VerifyConfirm(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if _, err := a.api.ConfirmVerificationCtx(c.Request().Context(), c.PathParam("token")); err != nil {
+			xlog.Error("error while confirming verification", "error", err)
+			return view.Render(c, http.StatusOK, MessageView("Invalid or expired link", "Your email couldn't be verified.",
+				component.Link("Request a new link", view.ReverseX(c, "account.verify"), templ.Attributes{"role": "button"}),
+			), nil)
+		}
+		return view.Render(c, http.StatusOK, MessageView("Email verification", "Your email has been verified.", nil), nil)
+	}
+}
+
+func (a AccountHandler) EmailChange(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.PathParam(a.pathParam)
+		attr := templ.Attributes{"hx-post": view.ReverseX(c, "account.email-change", id), "hx-target": "#content"}
+		if c.Request().Method == http.MethodGet {
+			return view.Render(c, http.StatusOK, EmailChangeFormView(errorsmap.New(), attr), nil)
+		}
+		var req pb.RequestNewEmail
+		if err := c.Bind(&req); err != nil {
+			return view.Render(c, http.StatusOK, EmailChangeFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		if _, err := a.GetClient(c.Request().Context()).RequestEmailChangeCtx(c.Request().Context(), req.NewEmail); err != nil {
+			xlog.Error("error while requesting email change", "user", id, "error", err)
+			return view.Render(c, http.StatusOK, EmailChangeFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		return view.Render(c, http.StatusOK, Message(
+			"Change email",
+			fmt.Sprintf("A confirmation link has been sent to %s.", req.NewEmail),
+			nil,
+		), nil)
+	}
+}
+
+func (a AccountHandler) EmailChangeConfirm(cx context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token := c.PathParam("token")
+		attr := templ.Attributes{"method": http.MethodPost, "action": view.ReverseX(c, "account.email-change.confirm", token)}
+		if c.Request().Method == http.MethodGet {
+			return view.Render(c, http.StatusOK, EmailChangeConfirmFormView(errorsmap.New(), attr), nil)
+		}
+		var req pb.RequestConfirmEmailChange
+		if err := c.Bind(&req); err != nil {
+			return view.Render(c, http.StatusOK, EmailChangeConfirmFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		req.Token = token
+		if _, err := a.api.ConfirmEmailChangeCtx(c.Request().Context(), req); err != nil {
+			xlog.Error("error while confirming email change", "error", err)
+			return view.Render(c, http.StatusOK, EmailChangeConfirmFormView(pb.ErrorsMap(err), attr), nil)
+		}
+		// PocketBase invalidates the existing tokens once the email changed
+		xsession.DeleteUser(c.Request().Context())
+		return view.Render(c, http.StatusOK, MessageView("Email change", "Your email has been changed, please sign in again.",
+			component.Link("Sign in", view.ReverseX(c, "account.login"), templ.Attributes{"role": "button"}),
+		), nil)
+	}
+}
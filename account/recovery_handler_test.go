@@ -0,0 +1,95 @@
+package account
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/josuebrunel/sportdropin/pkg/pbclient/pbtest"
+)
+
+func TestPasswordReset(t *testing.T) {
+	app := newTestApp(t)
+	app.pb.Seed("users", pbtest.Record{"username": "joe", "email": "joe@example.com", "password": "secret123"})
+
+	// the response doesn't tell whether the email is registered
+	_, known := app.form(t, http.MethodPost, "/account/password-reset", url.Values{"email": {"joe@example.com"}})
+	_, unknown := app.form(t, http.MethodPost, "/account/password-reset", url.Values{"email": {"nobody@example.com"}})
+	if !strings.Contains(known, "a link to reset its password has been sent") {
+		t.Fatalf("request: got %q", known)
+	}
+	if strings.ReplaceAll(known, "joe@example.com", "nobody@example.com") != unknown {
+		t.Errorf("unknown email answered differently: %q", unknown)
+	}
+	mails := app.pb.Mails()
+	if len(mails) != 1 || mails[0].To != "joe@example.com" || mails[0].Action != pbtest.MailPasswordReset {
+		t.Fatalf("unexpected mails %+v", mails)
+	}
+	path := "/account/password-reset/" + mails[0].Token
+
+	resp, body := app.do(t, http.MethodGet, path, "", nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, `action="`+path+`"`) {
+		t.Fatalf("confirm form: got %d %q", resp.StatusCode, body)
+	}
+	_, body = app.form(t, http.MethodPost, "/account/password-reset/expired", url.Values{"password": {"newsecret"}, "passwordConfirm": {"newsecret"}})
+	if !strings.Contains(body, "Invalid or expired link") {
+		t.Errorf("expired token: got %q", body)
+	}
+	_, body = app.form(t, http.MethodPost, path, url.Values{"password": {"newsecret"}, "passwordConfirm": {"other"}})
+	if !strings.Contains(body, "match") {
+		t.Errorf("mismatching passwords: got %q", body)
+	}
+	if users := app.pb.Records("users"); users[0]["password"] != "secret123" {
+		t.Fatalf("password changed by a rejected confirmation: %v", users[0])
+	}
+
+	_, body = app.form(t, http.MethodPost, path, url.Values{"password": {"newsecret"}, "passwordConfirm": {"newsecret"}})
+	if !strings.Contains(body, "Your password has been changed.") {
+		t.Fatalf("confirm: got %q", body)
+	}
+	app.login(t, "joe", "newsecret")
+	_, body = app.form(t, http.MethodPost, path, url.Values{"password": {"again123"}, "passwordConfirm": {"again123"}})
+	if !strings.Contains(body, "Invalid or expired link") {
+		t.Errorf("spent token: got %q", body)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	app := newTestApp(t)
+	app.pb.Seed("users", pbtest.Record{"username": "joe", "email": "joe@example.com", "password": "secret123"})
+
+	// the response doesn't tell whether the email is registered
+	_, known := app.form(t, http.MethodPost, "/account/verify", url.Values{"email": {"joe@example.com"}})
+	_, unknown := app.form(t, http.MethodPost, "/account/verify", url.Values{"email": {"nobody@example.com"}})
+	if !strings.Contains(known, "a verification link has been sent") {
+		t.Fatalf("request: got %q", known)
+	}
+	if strings.ReplaceAll(known, "joe@example.com", "nobody@example.com") != unknown {
+		t.Errorf("unknown email answered differently: %q", unknown)
+	}
+	mails := app.pb.Mails()
+	if len(mails) != 1 || mails[0].To != "joe@example.com" || mails[0].Action != pbtest.MailVerification {
+		t.Fatalf("unexpected mails %+v", mails)
+	}
+	path := "/account/verify/" + mails[0].Token
+
+	_, body := app.do(t, http.MethodGet, "/account/verify/expired", "", nil)
+	if !strings.Contains(body, "Invalid or expired link") {
+		t.Errorf("expired token: got %q", body)
+	}
+	if users := app.pb.Records("users"); users[0]["verified"] == true {
+		t.Fatal("verified with an expired token")
+	}
+	resp, body := app.do(t, http.MethodGet, path, "", nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "Your email has been verified.") {
+		t.Fatalf("confirm: got %d %q", resp.StatusCode, body)
+	}
+	if users := app.pb.Records("users"); users[0]["verified"] != true {
+		t.Errorf("user not verified: %v", users[0])
+	}
+	_, body = app.do(t, http.MethodGet, path, "", nil)
+	if !strings.Contains(body, "Invalid or expired link") {
+		t.Errorf("spent token: got %q", body)
+	}
+}
@@ -1,8 +1,10 @@
 package app
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/josuebrunel/sportdropin/account"
 	"github.com/josuebrunel/sportdropin/app/config"
@@ -14,6 +16,7 @@ import (
 	"github.com/labstack/echo/v5/middleware"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/cron"
 )
 
 type App struct {
@@ -28,67 +31,20 @@ func NewApp() App {
 func (a App) Run() {
 	// pocket base app
 	app := pocketbase.New()
+	retention := a.Opts.TrashRetention
 	app.OnBeforeServe().Add(func(e *core.ServeEvent) error {
 		ctx := app.RootCmd.Context()
 
 		e.Router.Use(middleware.Logger())
 		e.Router.Use(middleware.CORS())
 		e.Router.Use(middleware.Recover())
-		e.Router.Use(xsession.LoadAndSave(xsession.SessionManager))
+		groupHandler := routes(ctx, app, e.Router)
 
-		e.Router.Static("/static", "public")
-		e.Router.GET("/", func(c echo.Context) error { return view.Render(c, http.StatusOK, base.Index(), nil) })
-		groupHandler := group.NewGroupHandler(app.Dao(), app.Settings().Meta.AppUrl)
-		g := e.Router.Group("/group")
-		g.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
-			TokenLookup: "form:csrf,header:csrf",
-		}))
-		// GROUPS
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "", Handler: groupHandler.List(ctx), Name: "group.list"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid", Handler: groupHandler.Get(ctx), Name: "group.get"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/create", Handler: groupHandler.Create(ctx), Name: "group.create"})
-		g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/create", Handler: groupHandler.Create(ctx), Name: "group.created"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/edit", Handler: groupHandler.Update(ctx), Name: "group.update"})
-		g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:groupid/edit", Handler: groupHandler.Update(ctx), Name: "group.update"})
-		g.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:groupid", Handler: groupHandler.Delete(ctx), Name: "group.delete"})
-		// SEASONS
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/season/create", Handler: groupHandler.SeasonCreate(ctx), Name: "season.create"})
-		g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/:groupid/season/create", Handler: groupHandler.SeasonCreate(ctx), Name: "season.create"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/seasons", Handler: groupHandler.SeasonList(ctx), Name: "season.list"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/season/:seasonid/edit", Handler: groupHandler.SeasonEdit(ctx), Name: "season.edit"})
-		g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:groupid/season/:seasonid/edit", Handler: groupHandler.SeasonEdit(ctx), Name: "season.edit"})
-		g.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:groupid/season/:seasonid", Handler: groupHandler.SeasonDelete(ctx), Name: "season.delete"})
-		// MEMBERS
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/member/create", Handler: groupHandler.MemberCreate(ctx), Name: "member.create"})
-		g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/:groupid/member/create", Handler: groupHandler.MemberCreate(ctx), Name: "member.created"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/members", Handler: groupHandler.MemberList(ctx), Name: "member.list"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/member/:memberid/edit", Handler: groupHandler.MemberEdit(ctx), Name: "member.edit"})
-		g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:groupid/member/:memberid/edit", Handler: groupHandler.MemberEdit(ctx), Name: "member.edit"})
-		g.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:groupid/member/:memberid", Handler: groupHandler.MemberDelete(ctx), Name: "member.delete"})
-		// STATS
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/stat/create", Handler: groupHandler.StatCreate(ctx), Name: "stat.create"})
-		g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/:groupid/stat/create", Handler: groupHandler.StatCreate(ctx), Name: "stat.create"})
-		g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/stat/", Handler: groupHandler.StatList(ctx), Name: "stat.list"})
-		// ACCOUNTS
-		accountHandler := account.NewAccountHandler(app.App.Settings().Meta.AppUrl)
-		a := e.Router.Group("/account")
-		a.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
-			TokenLookup: "form:csrf,header:csrf",
-		}))
-		a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/login", Handler: accountHandler.Login(ctx), Name: "account.login"})
-		a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/login", Handler: accountHandler.Login(ctx), Name: "account.login"})
-		a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/logout", Handler: accountHandler.Logout(ctx), Name: "account.logout"})
-		a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid", Handler: accountHandler.Get(ctx), Name: "account.get",
-			Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
-		a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/register", Handler: accountHandler.Create(ctx), Name: "account.register"})
-		a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/register", Handler: accountHandler.Create(ctx), Name: "account.register"})
-		a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid/edit", Handler: accountHandler.Update(ctx), Name: "account.update",
-			Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
-		a.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:accountid/edit", Handler: accountHandler.Update(ctx), Name: "account.update",
-			Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
-		a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid/groups", Handler: accountHandler.Groups(ctx), Name: "account.groups",
-			Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
-		// a.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:accountid", Handler: accountHandler.Delete(ctx), Name: "account.delete"})
+		// JOBS
+		scheduler := cron.New()
+		scheduler.MustAdd("trash.purge", "0 3 * * *", func() { groupHandler.PurgeTrash(ctx, retention) })
+		scheduler.MustAdd("seasons.advance", "5 * * * *", func() { groupHandler.AdvanceSeasons(ctx, time.Now()) })
+		scheduler.Start()
 		return nil
 	})
 
@@ -96,3 +52,93 @@ func (a App) Run() {
 		log.Fatal("shutting down the server")
 	}
 }
+
+// routes registers the pages of the app on e, and the app hooks they need,
+// the groups and accounts being served from the records of app.
+func routes(ctx context.Context, app core.App, e *echo.Echo) *group.GroupHandler {
+	e.Use(xsession.LoadAndSave(xsession.SessionManager))
+
+	e.Static("/static", "public")
+	e.GET("/", func(c echo.Context) error { return view.Render(c, http.StatusOK, base.Index(), nil) })
+	groupHandler := group.NewGroupHandler(app.Dao(), app.Settings().Meta.AppUrl)
+	app.OnModelAfterUpdate("sports").Add(groupHandler.OnSportUpdate)
+	g := e.Group("/group")
+	g.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
+		TokenLookup: "form:csrf,header:csrf",
+	}))
+	g.Use(groupHandler.Authorize)
+	// GROUPS
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "", Handler: groupHandler.List(ctx), Name: "group.list"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid", Handler: groupHandler.Get(ctx), Name: "group.get"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/suggest", Handler: groupHandler.Suggest(ctx), Name: "group.suggest"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/create", Handler: groupHandler.Create(ctx), Name: "group.create"})
+	g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/create", Handler: groupHandler.Create(ctx), Name: "group.created"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/edit", Handler: groupHandler.Update(ctx), Name: "group.update"})
+	g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:groupid/edit", Handler: groupHandler.Update(ctx), Name: "group.update"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/delete", Handler: groupHandler.DeleteConfirm(ctx), Name: "group.delete.confirm"})
+	g.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:groupid", Handler: groupHandler.Delete(ctx), Name: "group.delete"})
+	// SEASONS
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/season/create", Handler: groupHandler.SeasonCreate(ctx), Name: "season.create"})
+	g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/:groupid/season/create", Handler: groupHandler.SeasonCreate(ctx), Name: "season.create"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/seasons", Handler: groupHandler.SeasonList(ctx), Name: "season.list"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/season/:seasonid/edit", Handler: groupHandler.SeasonEdit(ctx), Name: "season.edit"})
+	g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:groupid/season/:seasonid/edit", Handler: groupHandler.SeasonEdit(ctx), Name: "season.edit"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/season/:seasonid/delete", Handler: groupHandler.SeasonDeleteConfirm(ctx), Name: "season.delete.confirm"})
+	g.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:groupid/season/:seasonid", Handler: groupHandler.SeasonDelete(ctx), Name: "season.delete"})
+	// MEMBERS
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/member/create", Handler: groupHandler.MemberCreate(ctx), Name: "member.create"})
+	g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/:groupid/member/create", Handler: groupHandler.MemberCreate(ctx), Name: "member.created"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/members", Handler: groupHandler.MemberList(ctx), Name: "member.list"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/member/:memberid/edit", Handler: groupHandler.MemberEdit(ctx), Name: "member.edit"})
+	g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:groupid/member/:memberid/edit", Handler: groupHandler.MemberEdit(ctx), Name: "member.edit"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/member/:memberid/delete", Handler: groupHandler.MemberDeleteConfirm(ctx), Name: "member.delete.confirm"})
+	g.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:groupid/member/:memberid", Handler: groupHandler.MemberDelete(ctx), Name: "member.delete"})
+	// STATS
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/stat/create", Handler: groupHandler.StatCreate(ctx), Name: "stat.create"})
+	g.AddRoute(echo.Route{Method: http.MethodPost, Path: "/:groupid/stat/create", Handler: groupHandler.StatCreate(ctx), Name: "stat.create"})
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/stat/", Handler: groupHandler.StatList(ctx), Name: "stat.list"})
+	// HISTORY
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:groupid/history", Handler: groupHandler.History(ctx), Name: "history.list"})
+	g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:groupid/history/:historyid/revert", Handler: groupHandler.HistoryRevert(ctx), Name: "history.revert"})
+	// TRASH
+	g.AddRoute(echo.Route{Method: http.MethodGet, Path: "/trash", Handler: groupHandler.Trash(ctx), Name: "group.trash"})
+	g.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/trash/:kind/:trashid", Handler: groupHandler.TrashRestore(ctx), Name: "trash.restore"})
+	g.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/trash/:kind/:trashid", Handler: groupHandler.TrashPurge(ctx), Name: "trash.purge"})
+	// ACCOUNTS
+	accountHandler := account.NewAccountHandler(app.Settings().Meta.AppUrl)
+	a := e.Group("/account")
+	a.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
+		TokenLookup: "form:csrf,header:csrf",
+	}))
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/login", Handler: accountHandler.Login(ctx), Name: "account.login"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/login", Handler: accountHandler.Login(ctx), Name: "account.login"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/logout", Handler: accountHandler.Logout(ctx), Name: "account.logout"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/oauth/:provider", Handler: accountHandler.OAuth2(ctx), Name: "account.oauth"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/oauth/:provider/callback", Handler: accountHandler.OAuth2Callback(ctx), Name: "account.oauth.callback"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid", Handler: accountHandler.Get(ctx), Name: "account.get",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/register", Handler: accountHandler.Create(ctx), Name: "account.register"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/register", Handler: accountHandler.Create(ctx), Name: "account.register"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid/edit", Handler: accountHandler.Update(ctx), Name: "account.update",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodPatch, Path: "/:accountid/edit", Handler: accountHandler.Update(ctx), Name: "account.update",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid/groups", Handler: accountHandler.Groups(ctx), Name: "account.groups",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/password-reset", Handler: accountHandler.PasswordReset(ctx), Name: "account.password-reset"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/password-reset", Handler: accountHandler.PasswordReset(ctx), Name: "account.password-reset"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/password-reset/:token", Handler: accountHandler.PasswordResetConfirm(ctx), Name: "account.password-reset.confirm"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/password-reset/:token", Handler: accountHandler.PasswordResetConfirm(ctx), Name: "account.password-reset.confirm"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/verify", Handler: accountHandler.Verify(ctx), Name: "account.verify"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/verify", Handler: accountHandler.Verify(ctx), Name: "account.verify"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/verify/:token", Handler: accountHandler.VerifyConfirm(ctx), Name: "account.verify.confirm"})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/:accountid/email", Handler: accountHandler.EmailChange(ctx), Name: "account.email-change",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/:accountid/email", Handler: accountHandler.EmailChange(ctx), Name: "account.email-change",
+		Middlewares: []echo.MiddlewareFunc{xsession.LoginRequired}})
+	a.AddRoute(echo.Route{Method: http.MethodGet, Path: "/email-change/:token", Handler: accountHandler.EmailChangeConfirm(ctx), Name: "account.email-change.confirm"})
+	a.AddRoute(echo.Route{Method: http.MethodPost, Path: "/email-change/:token", Handler: accountHandler.EmailChangeConfirm(ctx), Name: "account.email-change.confirm"})
+	// a.AddRoute(echo.Route{Method: http.MethodDelete, Path: "/:accountid", Handler: accountHandler.Delete(ctx), Name: "account.delete"})
+
+	return groupHandler
+}
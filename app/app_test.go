@@ -0,0 +1,641 @@
+// The PocketBase collections can't be decoded with the encoding/json v2
+// experiment, see pkg/service/service_test.go.
+
+//go:build !goexperiment.jsonv2
+
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/migrate"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// testApp serves the routes and the PocketBase API over a fresh database.
+type testApp struct {
+	dao *daos.Dao
+	web *httptest.Server
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+	runner, err := migrate.NewRunner(app.DB(), migrations.AppMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.DB().NewQuery("CREATE VIRTUAL TABLE temp.fts5_probe USING fts5(x)").Execute(); err != nil {
+		t.Fatal("the search index needs SQLite FTS5, run the tests with -tags sqlite_fts5:", err)
+	}
+	newTestSchema(t, app.Dao())
+
+	var router *echo.Echo
+	web := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { router.ServeHTTP(w, r) }))
+	t.Cleanup(web.Close)
+	app.Settings().Meta.AppUrl = web.URL
+	if router, err = apis.InitApi(app); err != nil {
+		t.Fatal(err)
+	}
+	routes(context.Background(), app, router)
+	return &testApp{dao: app.Dao(), web: web}
+}
+
+// newTestSchema creates the collections of the app, readable through the
+// API by anyone.
+func newTestSchema(t *testing.T, dao *daos.Dao) {
+	t.Helper()
+	users, err := dao.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(name string) *schema.SchemaField {
+		return &schema.SchemaField{Name: name, Type: schema.FieldTypeText}
+	}
+	date := func(name string) *schema.SchemaField {
+		return &schema.SchemaField{Name: name, Type: schema.FieldTypeDate}
+	}
+	json := func(name string) *schema.SchemaField {
+		return &schema.SchemaField{Name: name, Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 1 << 16}}
+	}
+	relation := func(name string, to *models.Collection, maxSelect int) *schema.SchemaField {
+		return &schema.SchemaField{Name: name, Type: schema.FieldTypeRelation, Options: &schema.RelationOptions{
+			CollectionId: to.Id,
+			MaxSelect:    types.Pointer(maxSelect),
+		}}
+	}
+	collection := func(name string, fields ...*schema.SchemaField) *models.Collection {
+		c := &models.Collection{
+			Name:     name,
+			Type:     models.CollectionTypeBase,
+			Schema:   schema.NewSchema(fields...),
+			ListRule: types.Pointer(""),
+			ViewRule: types.Pointer(""),
+		}
+		if err := dao.SaveCollection(c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	sports := collection("sports", text("name"), text("icon"), json("data"))
+	groups := collection("groups",
+		relation("user", users, 1), relation("managers", users, 10), relation("sport", sports, 1),
+		text("name"), text("description"), text("street"), text("city"), text("country"), date(service.DeletedField))
+	seasons := collection("seasons",
+		relation("group", groups, 1), text("name"), text("status"), date("start_date"), date("end_date"), date(service.DeletedField))
+	members := collection("members",
+		relation("group", groups, 1), text("username"), text("email"), text("phone"), date(service.DeletedField))
+	collection("memberstats",
+		relation("group", groups, 1), relation("member", members, 1), relation("season", seasons, 1), json("stats"))
+}
+
+// record saves a record of collection with data.
+func (app *testApp) record(t *testing.T, collection string, data map[string]any) *models.Record {
+	t.Helper()
+	c, err := app.dao.FindCollectionByNameOrId(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := models.NewRecord(c)
+	r.Load(data)
+	if err := app.dao.SaveRecord(r); err != nil {
+		t.Fatalf("save %s %v: %v", collection, data, err)
+	}
+	return r
+}
+
+func (app *testApp) user(t *testing.T, username string) *models.Record {
+	t.Helper()
+	c, err := app.dao.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := models.NewRecord(c)
+	u.SetUsername(username)
+	u.SetEmail(username + "@example.com")
+	u.SetPassword("secret123")
+	if err := app.dao.SaveRecord(u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// find returns the record id of collection, trashed or not.
+func (app *testApp) find(t *testing.T, collection, id string) *models.Record {
+	t.Helper()
+	r, err := app.dao.FindRecordById(collection, id)
+	if err != nil {
+		t.Fatalf("find %s %s: %v", collection, id, err)
+	}
+	return r
+}
+
+// client is a browser session, logged in as username unless empty.
+func (app *testApp) client(t *testing.T, username string) *http.Client {
+	t.Helper()
+	jar, _ := cookiejar.New(nil)
+	c := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	// fetches the CSRF cookie
+	app.do(t, c, http.MethodGet, "/group", nil)
+	if username != "" {
+		resp, _ := app.do(t, c, http.MethodPost, "/account/login", url.Values{"username": {username}, "password": {"secret123"}})
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("login %s: got status %d", username, resp.StatusCode)
+		}
+	}
+	return c
+}
+
+// do sends form with the CSRF token of c.
+func (app *testApp) do(t *testing.T, c *http.Client, method, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+	if form == nil {
+		return app.send(t, c, method, path, "", nil)
+	}
+	return app.send(t, c, method, path, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+}
+
+func (app *testApp) send(t *testing.T, c *http.Client, method, path, contentType string, body io.Reader) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, app.web.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, cookie := range c.Jar.Cookies(req.URL) {
+		if cookie.Name == "_csrf" {
+			req.Header.Set("csrf", cookie.Value)
+		}
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+// league is a group of its owner, managed by manager, with a season in
+// progress and a member.
+type league struct {
+	sport, group, season, member, history, trashed string
+}
+
+func newLeague(t *testing.T, app *testApp, owner, manager *models.Record) league {
+	t.Helper()
+	sport := app.record(t, "sports", map[string]any{
+		"name": "Soccer",
+		"data": `{"top":{"abbr":"g"},"stats":[
+			{"abbr":"g","name":"Goals","step":"1","type":"number"},
+			{"abbr":"km","name":"Distance","step":"0.1","type":"number"}
+		]}`,
+	})
+	group := app.record(t, "groups", map[string]any{
+		"user": owner.Id, "managers": []string{manager.Id}, "sport": sport.Id,
+		"name": "Lions", "street": "1 rue du Stade", "city": "Paris", "country": "France",
+	})
+	season := app.record(t, "seasons", map[string]any{
+		"group": group.Id, "name": "2024", "status": "inprogress", "start_date": "2024-01-01", "end_date": "2099-12-31",
+	})
+	member := app.record(t, "members", map[string]any{"group": group.Id, "username": "zizou"})
+	trashed := app.record(t, "members", map[string]any{"group": group.Id, "username": "gone", service.DeletedField: types.NowDateTime()})
+	history := app.record(t, service.HistoryCollection, map[string]any{
+		"scope": group.Id, "collection": "groups", "record": group.Id, "op": "update",
+		"diff": map[string]any{"city": map[string]any{"old": "Paris", "new": "Lyon"}},
+	})
+	return league{sport: sport.Id, group: group.Id, season: season.Id, member: member.Id, history: history.Id, trashed: trashed.Id}
+}
+
+func TestRoutes(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager := app.user(t, "owner"), app.user(t, "manager")
+	app.user(t, "joe")
+	l := newLeague(t, app, owner, manager)
+	clients := map[string]*http.Client{
+		"anonymous": app.client(t, ""),
+		"user":      app.client(t, "joe"),
+		"manager":   app.client(t, "manager"),
+		"owner":     app.client(t, "owner"),
+	}
+	g := "/group/" + l.group
+
+	tests := []struct {
+		name   string
+		as     string
+		method string
+		path   string
+		form   url.Values
+		status int
+	}{
+		{"home", "anonymous", http.MethodGet, "/", nil, http.StatusOK},
+		// GROUPS
+		{"group list", "anonymous", http.MethodGet, "/group", nil, http.StatusOK},
+		{"group search", "anonymous", http.MethodGet, "/group?search=lion", nil, http.StatusOK},
+		{"group get", "anonymous", http.MethodGet, g, nil, http.StatusSeeOther},
+		{"group suggest", "anonymous", http.MethodGet, "/group/suggest?search=par", nil, http.StatusOK},
+		{"group create form anonymous", "anonymous", http.MethodGet, "/group/create", nil, http.StatusForbidden},
+		{"group create form", "user", http.MethodGet, "/group/create", nil, http.StatusOK},
+		{"group create", "user", http.MethodPost, "/group/create", url.Values{
+			"name": {"Tigers"}, "sport": {l.sport}, "street": {"2 rue"}, "city": {"Lyon"}, "country": {"France"},
+		}, http.StatusFound},
+		{"group edit form user", "user", http.MethodGet, g + "/edit", nil, http.StatusForbidden},
+		{"group edit form", "manager", http.MethodGet, g + "/edit", nil, http.StatusOK},
+		{"group edit user", "user", http.MethodPatch, g + "/edit", url.Values{"name": {"Hacked"}}, http.StatusForbidden},
+		{"group edit", "manager", http.MethodPatch, g + "/edit", url.Values{"name": {"Lions FC"}}, http.StatusSeeOther},
+		{"group delete confirm manager", "manager", http.MethodGet, g + "/delete", nil, http.StatusForbidden},
+		{"group delete confirm", "owner", http.MethodGet, g + "/delete", nil, http.StatusOK},
+		{"group delete manager", "manager", http.MethodDelete, g, nil, http.StatusForbidden},
+		{"group unknown", "owner", http.MethodGet, "/group/unknown/edit", nil, http.StatusNotFound},
+		// SEASONS
+		{"season create form user", "user", http.MethodGet, g + "/season/create", nil, http.StatusForbidden},
+		{"season create form", "manager", http.MethodGet, g + "/season/create", nil, http.StatusOK},
+		{"season create", "manager", http.MethodPost, g + "/season/create", url.Values{
+			"name": {"2025"}, "status": {"scheduled"}, "start_date": {"2025-01-01"}, "end_date": {"2025-12-31"},
+		}, http.StatusOK},
+		{"season list user", "user", http.MethodGet, g + "/seasons", nil, http.StatusForbidden},
+		{"season list", "manager", http.MethodGet, g + "/seasons", nil, http.StatusOK},
+		{"season edit form", "manager", http.MethodGet, g + "/season/" + l.season + "/edit", nil, http.StatusOK},
+		{"season edit", "manager", http.MethodPatch, g + "/season/" + l.season + "/edit", url.Values{"name": {"2024/25"}}, http.StatusOK},
+		{"season edit user", "user", http.MethodPatch, g + "/season/" + l.season + "/edit", url.Values{"name": {"x"}}, http.StatusForbidden},
+		{"season of another group", "manager", http.MethodGet, "/group/unknown/season/" + l.season + "/edit", nil, http.StatusNotFound},
+		{"season delete confirm", "manager", http.MethodGet, g + "/season/" + l.season + "/delete", nil, http.StatusOK},
+		// MEMBERS
+		{"member create form user", "user", http.MethodGet, g + "/member/create", nil, http.StatusForbidden},
+		{"member create form", "manager", http.MethodGet, g + "/member/create", nil, http.StatusOK},
+		{"member create", "manager", http.MethodPost, g + "/member/create", url.Values{"username": {"thierry"}}, http.StatusOK},
+		{"member list user", "user", http.MethodGet, g + "/members", nil, http.StatusForbidden},
+		{"member list", "manager", http.MethodGet, g + "/members", nil, http.StatusOK},
+		{"member edit form", "manager", http.MethodGet, g + "/member/" + l.member + "/edit", nil, http.StatusOK},
+		{"member edit", "manager", http.MethodPatch, g + "/member/" + l.member + "/edit", url.Values{"username": {"zz"}}, http.StatusOK},
+		{"member edit user", "user", http.MethodPatch, g + "/member/" + l.member + "/edit", url.Values{"username": {"x"}}, http.StatusForbidden},
+		{"member delete confirm", "manager", http.MethodGet, g + "/member/" + l.member + "/delete", nil, http.StatusOK},
+		// STATS
+		{"stat list", "anonymous", http.MethodGet, g + "/stat/", nil, http.StatusOK},
+		{"stat create form user", "user", http.MethodGet, g + "/stat/create", nil, http.StatusForbidden},
+		{"stat create form", "manager", http.MethodGet, g + "/stat/create", nil, http.StatusOK},
+		{"stat create user", "user", http.MethodPost, g + "/stat/create", url.Values{"season": {l.season}}, http.StatusForbidden},
+		{"stat create", "manager", http.MethodPost, g + "/stat/create", statSheet(l.season, l.member, nil, url.Values{"g": {"2"}}), http.StatusOK},
+		// HISTORY
+		{"history user", "user", http.MethodGet, g + "/history", nil, http.StatusForbidden},
+		{"history", "manager", http.MethodGet, g + "/history", nil, http.StatusOK},
+		{"history revert user", "user", http.MethodPatch, g + "/history/" + l.history + "/revert", url.Values{"field": {"city"}}, http.StatusForbidden},
+		{"history revert", "manager", http.MethodPatch, g + "/history/" + l.history + "/revert", url.Values{"field": {"city"}}, http.StatusOK},
+		// TRASH
+		{"trash anonymous", "anonymous", http.MethodGet, "/group/trash", nil, http.StatusForbidden},
+		{"trash", "owner", http.MethodGet, "/group/trash", nil, http.StatusOK},
+		{"trash restore", "owner", http.MethodPatch, "/group/trash/member/" + l.trashed, nil, http.StatusOK},
+		{"trash purge anonymous", "anonymous", http.MethodDelete, "/group/trash/member/" + l.trashed, nil, http.StatusForbidden},
+		// the deletions go last
+		{"member delete", "manager", http.MethodDelete, g + "/member/" + l.member, nil, http.StatusOK},
+		{"season delete", "manager", http.MethodDelete, g + "/season/" + l.season, nil, http.StatusOK},
+		{"trash purge", "owner", http.MethodDelete, "/group/trash/member/" + l.member, nil, http.StatusOK},
+		{"group delete", "owner", http.MethodDelete, g, nil, http.StatusSeeOther},
+		// ACCOUNTS
+		{"login form", "anonymous", http.MethodGet, "/account/login", nil, http.StatusOK},
+		{"login", "anonymous", http.MethodPost, "/account/login", url.Values{"username": {"joe"}, "password": {"wrong"}}, http.StatusOK},
+		{"account anonymous", "anonymous", http.MethodGet, "/account/" + owner.Id, nil, http.StatusFound},
+		{"account", "owner", http.MethodGet, "/account/" + owner.Id, nil, http.StatusOK},
+		{"account groups", "owner", http.MethodGet, "/account/" + owner.Id + "/groups", nil, http.StatusOK},
+		{"account edit form", "owner", http.MethodGet, "/account/" + owner.Id + "/edit", nil, http.StatusOK},
+		{"account edit", "owner", http.MethodPatch, "/account/" + owner.Id + "/edit", url.Values{"name": {"Owner"}}, http.StatusOK},
+		{"account email form", "owner", http.MethodGet, "/account/" + owner.Id + "/email", nil, http.StatusOK},
+		{"email change confirm form", "anonymous", http.MethodGet, "/account/email-change/token", nil, http.StatusOK},
+		{"email change confirm", "anonymous", http.MethodPost, "/account/email-change/token", url.Values{"password": {"secret123"}}, http.StatusOK},
+		{"register form", "anonymous", http.MethodGet, "/account/register", nil, http.StatusOK},
+		{"register", "anonymous", http.MethodPost, "/account/register", url.Values{
+			"email": {"new@example.com"}, "username": {"new"}, "password": {"secret123"}, "passwordConfirm": {"secret123"},
+		}, http.StatusFound},
+		{"oauth unknown provider", "anonymous", http.MethodGet, "/account/oauth/google", nil, http.StatusNotFound},
+		{"oauth callback", "anonymous", http.MethodGet, "/account/oauth/google/callback?state=x&code=y", nil, http.StatusOK},
+		{"password reset form", "anonymous", http.MethodGet, "/account/password-reset", nil, http.StatusOK},
+		{"password reset confirm form", "anonymous", http.MethodGet, "/account/password-reset/token", nil, http.StatusOK},
+		{"password reset confirm", "anonymous", http.MethodPost, "/account/password-reset/token", url.Values{
+			"password": {"secret456"}, "passwordConfirm": {"secret456"},
+		}, http.StatusOK},
+		{"verify form", "anonymous", http.MethodGet, "/account/verify", nil, http.StatusOK},
+		{"verify confirm", "anonymous", http.MethodGet, "/account/verify/token", nil, http.StatusOK},
+		{"logout", "owner", http.MethodGet, "/account/logout", nil, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		resp, body := app.do(t, clients[tt.as], tt.method, tt.path, tt.form)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: %s %s as %s: got status %d, want %d: %.300s", tt.name, tt.method, tt.path, tt.as, resp.StatusCode, tt.status, body)
+		}
+	}
+}
+
+func TestEditKeepsManagersAndTrash(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager := app.user(t, "owner"), app.user(t, "manager")
+	l := newLeague(t, app, owner, manager)
+	c := app.client(t, "manager")
+	g := "/group/" + l.group
+	deleted := types.NowDateTime().String()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		collection  string
+		id          string
+		field       string
+	}{
+		{"group json", g + "/edit", "application/json",
+			`{"name":"Lions FC","user":"` + manager.Id + `","managers":[],"deleted":"` + deleted + `"}`, "groups", l.group, "name"},
+		{"group form", g + "/edit", "application/x-www-form-urlencoded",
+			url.Values{"name": {"Lions"}, "managers": {""}, "deleted": {deleted}}.Encode(), "groups", l.group, "name"},
+		{"season json", g + "/season/" + l.season + "/edit", "application/json",
+			`{"name":"2024/25","deleted":"` + deleted + `"}`, "seasons", l.season, "name"},
+		{"member json", g + "/member/" + l.member + "/edit", "application/json",
+			`{"username":"zz","deleted":"` + deleted + `"}`, "members", l.member, "username"},
+		{"member form", g + "/member/" + l.member + "/edit", "application/x-www-form-urlencoded",
+			url.Values{"username": {"zizou"}, "deleted": {deleted}}.Encode(), "members", l.member, "username"},
+	}
+	for _, tt := range tests {
+		before := app.find(t, tt.collection, tt.id)
+		resp, body := app.send(t, c, http.MethodPatch, tt.path, tt.contentType, strings.NewReader(tt.body))
+		if resp.StatusCode >= http.StatusBadRequest {
+			t.Fatalf("%s: got status %d: %.300s", tt.name, resp.StatusCode, body)
+		}
+		after := app.find(t, tt.collection, tt.id)
+		if after.GetString(tt.field) == before.GetString(tt.field) {
+			t.Errorf("%s: %s not updated: %.300s", tt.name, tt.field, body)
+		}
+		if !after.GetDateTime(service.DeletedField).IsZero() {
+			t.Errorf("%s: trashed by an edit", tt.name)
+		}
+		if tt.collection == "groups" {
+			if got := after.GetStringSlice("managers"); len(got) != 1 || got[0] != manager.Id || after.GetString("user") != owner.Id {
+				t.Errorf("%s: got user %s and managers %v, want %s and [%s]", tt.name, after.GetString("user"), got, owner.Id, manager.Id)
+			}
+		}
+	}
+}
+
+func TestCreateWithoutManagersAndTrash(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager := app.user(t, "owner"), app.user(t, "manager")
+	l := newLeague(t, app, owner, manager)
+	joe := app.user(t, "joe")
+	c := app.client(t, "joe")
+	deleted := types.NowDateTime().String()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"json", "application/json",
+			`{"name":"Tigers","sport":"` + l.sport + `","street":"2 rue","city":"Lyon","country":"France","managers":["` + manager.Id + `"],"deleted":"` + deleted + `"}`},
+		{"form", "application/x-www-form-urlencoded", url.Values{
+			"name": {"Bears"}, "sport": {l.sport}, "street": {"3 rue"}, "city": {"Lyon"}, "country": {"France"},
+			"managers": {manager.Id}, "deleted": {deleted},
+		}.Encode()},
+	}
+	for _, tt := range tests {
+		resp, body := app.send(t, c, http.MethodPost, "/group/create", tt.contentType, strings.NewReader(tt.body))
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("%s: got status %d: %.300s", tt.name, resp.StatusCode, body)
+		}
+	}
+	groups, err := app.dao.FindRecordsByFilter("groups", "user = {:user}", "", 0, 0, dbx.Params{"user": joe.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != len(tests) {
+		t.Fatalf("got %d groups, want %d", len(groups), len(tests))
+	}
+	for _, g := range groups {
+		if got := g.GetStringSlice("managers"); len(got) != 0 {
+			t.Errorf("%s: got managers %v", g.GetString("name"), got)
+		}
+		if !g.GetDateTime(service.DeletedField).IsZero() {
+			t.Errorf("%s: created trashed", g.GetString("name"))
+		}
+	}
+}
+
+func TestSportRename(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager := app.user(t, "owner"), app.user(t, "manager")
+	l := newLeague(t, app, owner, manager)
+	sport := app.find(t, "sports", l.sport)
+	sport.Set("name", "Football")
+	if err := app.dao.SaveRecord(sport); err != nil {
+		t.Fatal(err)
+	}
+	_, body := app.do(t, app.client(t, ""), http.MethodGet, "/group?search=football", nil)
+	if !strings.Contains(body, "Lions") {
+		t.Errorf("renamed sport not found: %.300s", body)
+	}
+}
+
+func TestHistoryRevert(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager, joe := app.user(t, "owner"), app.user(t, "manager"), app.user(t, "joe")
+	l := newLeague(t, app, owner, manager)
+	c := app.client(t, "manager")
+	entry := func(op, field string, old any) string {
+		return app.record(t, service.HistoryCollection, map[string]any{
+			"scope": l.group, "collection": "groups", "record": l.group, "op": op,
+			"diff": map[string]any{field: map[string]any{"old": old}},
+		}).Id
+	}
+
+	tests := []struct {
+		name    string
+		history string
+		field   string
+		// want is the value of field after the request
+		want string
+	}{
+		{"update", entry("update", "city", "Lyon"), "city", "Lyon"},
+		{"create", entry("create", "name", "Tigers"), "name", "Lions"},
+		{"trash", entry("trash", service.DeletedField, types.NowDateTime().String()), service.DeletedField, ""},
+		{"managers", entry("update", "managers", []string{joe.Id}), "managers", manager.Id},
+		{"owner", entry("update", "user", manager.Id), "user", owner.Id},
+		{"field left out of the diff", entry("update", "city", "Nice"), "country", "France"},
+	}
+	for _, tt := range tests {
+		resp, body := app.do(t, c, http.MethodPatch, "/group/"+l.group+"/history/"+tt.history+"/revert", url.Values{"field": {tt.field}})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d: %.300s", tt.name, resp.StatusCode, body)
+		}
+		group := app.find(t, "groups", l.group)
+		got := group.GetString(tt.field)
+		if tt.field == "managers" {
+			got = strings.Join(group.GetStringSlice(tt.field), ",")
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s %q, want %q: %.300s", tt.name, tt.field, got, tt.want, body)
+		}
+	}
+}
+
+// statSheet returns the stat sheet form of the member, stat being its
+// stored stats if any.
+func statSheet(season, member string, stat *models.Record, values url.Values) url.Values {
+	form := url.Values{"season": {season}, member + ":member": {member}, member + ":id": {""}, member + ":updated": {""}}
+	if stat != nil {
+		form.Set(member+":id", stat.Id)
+		form.Set(member+":updated", stat.GetString("updated"))
+	}
+	for k, v := range values {
+		form[member+":"+k] = v
+	}
+	return form
+}
+
+// stats returns the stored stats of member for season.
+func (app *testApp) stats(t *testing.T, season, member string) (*models.Record, map[string]any) {
+	t.Helper()
+	r, err := app.dao.FindFirstRecordByFilter("memberstats", "season = {:season} && member = {:member}", dbx.Params{"season": season, "member": member})
+	if err != nil {
+		t.Fatalf("stats of %s: %v", member, err)
+	}
+	stats := map[string]any{}
+	if err := r.UnmarshalJSONField("stats", &stats); err != nil {
+		t.Fatal(err)
+	}
+	return r, stats
+}
+
+func TestStatCreate(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager := app.user(t, "owner"), app.user(t, "manager")
+	l := newLeague(t, app, owner, manager)
+	c := app.client(t, "manager")
+	path := "/group/" + l.group + "/stat/create"
+
+	tests := []struct {
+		name   string
+		values url.Values
+		want   map[string]any
+	}{
+		{"create", url.Values{"g": {"3"}, "km": {"10.5"}}, map[string]any{"g": 3.0, "km": 10.5}},
+		{"update", url.Values{"g": {"5"}, "km": {"7.25"}}, map[string]any{"g": 5.0, "km": 7.3}},
+	}
+	var stat *models.Record
+	for _, tt := range tests {
+		resp, body := app.do(t, c, http.MethodPost, path, statSheet(l.season, l.member, stat, tt.values))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: got status %d: %.300s", tt.name, resp.StatusCode, body)
+		}
+		var got map[string]any
+		stat, got = app.stats(t, l.season, l.member)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got stats %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	_, body := app.do(t, c, http.MethodGet, path+"?season="+l.season, nil)
+	if !strings.Contains(body, `value="5"`) || !strings.Contains(body, `value="7.3"`) {
+		t.Errorf("edit form misses the stored stats: %.500s", body)
+	}
+	// a rejected sheet keeps the submitted values
+	_, body = app.do(t, c, http.MethodPost, path, statSheet(l.season, l.member, stat, url.Values{"g": {"4"}, "km": {"far"}}))
+	if !strings.Contains(body, `value="4"`) || !strings.Contains(body, `value="far"`) {
+		t.Errorf("rejected sheet misses the submitted stats: %.500s", body)
+	}
+	if _, got := app.stats(t, l.season, l.member); got["g"] != 5.0 {
+		t.Errorf("rejected sheet saved, got %v", got)
+	}
+}
+
+func TestStatCreateWithoutSeason(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager := app.user(t, "owner"), app.user(t, "manager")
+	l := newLeague(t, app, owner, manager)
+	c := app.client(t, "manager")
+	path := "/group/" + l.group + "/stat/create"
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"missing", "application/x-www-form-urlencoded", url.Values{l.member + ":g": {"2"}}.Encode()},
+		{"empty", "application/x-www-form-urlencoded", url.Values{"season": {""}, l.member + ":g": {"2"}}.Encode()},
+		{"number", "application/json", `{"season": 1, "` + l.member + `:g": "2"}`},
+	}
+	for _, tt := range tests {
+		resp, body := app.send(t, c, http.MethodPost, path, tt.contentType, strings.NewReader(tt.body))
+		if resp.StatusCode != http.StatusOK || !strings.Contains(body, "season of the stats is missing") {
+			t.Errorf("%s: got %d %.300s", tt.name, resp.StatusCode, body)
+		}
+	}
+	n := 0
+	if err := app.dao.RecordQuery("memberstats").Select("count(*)").Row(&n); err != nil || n != 0 {
+		t.Errorf("got %d stats, %v", n, err)
+	}
+}
+
+func TestStatCreateOtherGroup(t *testing.T) {
+	app := newTestApp(t)
+	owner, manager, rival := app.user(t, "owner"), app.user(t, "manager"), app.user(t, "rival")
+	a, b := newLeague(t, app, owner, manager), newLeague(t, app, rival, rival)
+	c := app.client(t, "manager")
+	path := "/group/" + a.group + "/stat/create"
+	stat := app.record(t, "memberstats", map[string]any{
+		"group": b.group, "season": b.season, "member": b.member, "stats": map[string]any{"g": 1},
+	})
+	want := stat.GetString("stats")
+
+	tests := []struct {
+		name   string
+		values url.Values
+	}{
+		{"season", statSheet(b.season, a.member, nil, url.Values{"g": {"99"}})},
+		{"member", statSheet(a.season, b.member, nil, url.Values{"g": {"99"}})},
+		{"stat", statSheet(a.season, a.member, stat, url.Values{"g": {"99"}})},
+		{"all", statSheet(b.season, b.member, stat, url.Values{"g": {"99"}})},
+	}
+	for _, tt := range tests {
+		if resp, body := app.do(t, c, http.MethodPost, path, tt.values); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: got status %d: %.300s", tt.name, resp.StatusCode, body)
+		}
+	}
+	got, err := app.dao.FindRecordById("memberstats", stat.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetString("group") != b.group || got.GetString("member") != b.member || got.GetString("stats") != want {
+		t.Errorf("stat of the other group changed: %v", got)
+	}
+	n := 0
+	if err := app.dao.RecordQuery("memberstats").Select("count(*)").Row(&n); err != nil || n != 1 {
+		t.Errorf("got %d stats, %v", n, err)
+	}
+}
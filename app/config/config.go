@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 )
 
 type Config struct {
@@ -13,6 +14,9 @@ type Config struct {
 	DBPass   string
 	DBName   string
 	TPLPath  string
+	// TrashRetention is how long the deleted groups, seasons and members
+	// stay in the trash.
+	TrashRetention time.Duration
 }
 
 func (cfg Config) GetDBDSN() string {
@@ -43,5 +47,9 @@ func NewConfig() Config {
 	cfg.DBPass = getValue("SDI_DB_PASSWORD")
 	cfg.DBName = getValue("SDI_DB_NAME")
 	cfg.TPLPath = getValue("SDI_TEMPLATES_PATH")
+	cfg.TrashRetention = 30 * 24 * time.Hour
+	if d, err := time.ParseDuration(getValue("SDI_TRASH_RETENTION")); err == nil {
+		cfg.TrashRetention = d
+	}
 	return cfg
 }
@@ -0,0 +1,94 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/josuebrunel/sportdropin/pkg/authz"
+	"github.com/josuebrunel/sportdropin/pkg/models"
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/josuebrunel/sportdropin/pkg/view"
+	"github.com/josuebrunel/sportdropin/pkg/view/component"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/josuebrunel/sportdropin/pkg/xsession"
+	"github.com/labstack/echo/v5"
+)
+
+var ErrNotFound = errors.New("not found")
+
+func groupResource(kind string, g models.Group) authz.Resource {
+	return authz.Resource{Kind: kind, Owner: g.User, Managers: g.Managers}
+}
+
+// can reports whether the session user may run action on the kind
+// resources of g.
+func can(ctx context.Context, action authz.Action, kind string, g models.Group) bool {
+	return authz.Can(xsession.GetUser(ctx).ID, action, groupResource(kind, g))
+}
+
+// routeAction returns the kind of resource and the action of a /group
+// route, i.e. PATCH /group/:groupid/member/:memberid/edit updates a member.
+func routeAction(method, path, groupParam string) (string, authz.Action) {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	kind := authz.Group
+	if len(segs) > 1 && segs[1] == authz.Trash {
+		kind = authz.Trash
+	}
+	for i, s := range segs {
+		if s == ":"+groupParam && i+1 < len(segs) && segs[i+1] != "edit" && segs[i+1] != "delete" {
+			kind = strings.TrimSuffix(segs[i+1], "s")
+		}
+	}
+	switch method {
+	case http.MethodPost:
+		return kind, authz.Create
+	case http.MethodPatch, http.MethodPut:
+		return kind, authz.Update
+	case http.MethodDelete:
+		return kind, authz.Delete
+	}
+	switch segs[len(segs)-1] {
+	case "create":
+		return kind, authz.Create
+	case "edit":
+		return kind, authz.Update
+	case "delete":
+		return kind, authz.Delete
+	}
+	return kind, authz.View
+}
+
+// Authorize checks the session user is allowed by authz.Policy to use the
+// /group route. The season and member of the route must belong to its
+// group.
+func (h GroupHandler) Authorize(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		kind, action := routeAction(ctx.Request().Method, ctx.Path(), h.svc.GetID())
+		var group models.Group
+		if groupID := ctx.PathParam(h.svc.GetID()); groupID != "" {
+			vd, err := h.repo.Get(ctx.Request().Context(), groupID)
+			if err != nil {
+				return view.Render(ctx, http.StatusNotFound, component.Error(ErrNotFound.Error()), nil)
+			}
+			group = vd.V()
+			for _, svc := range []service.Service{seasonSVC, memberSVC} {
+				id := ctx.PathParam(svc.GetID())
+				if id == "" {
+					continue
+				}
+				vd, err := svc.GetByID(ctx.Request().Context(), id)
+				if err != nil || vd.V().GetString("group") != groupID {
+					return view.Render(ctx, http.StatusNotFound, component.Error(ErrNotFound.Error()), nil)
+				}
+			}
+		}
+		user := xsession.GetUser(ctx.Request().Context())
+		if !authz.Can(user.ID, action, groupResource(kind, group)) {
+			xlog.Debug("forbidden", "user", user.ID, "action", action, "kind", kind, "group", group.ID)
+			return view.Render(ctx, http.StatusForbidden, component.Error(authz.ErrForbidden.Error()), nil)
+		}
+		return next(ctx)
+	}
+}
@@ -2,8 +2,8 @@ package group
 
 import (
 	"context"
+	"errors"
 	"net/http"
-	"sort"
 	"strings"
 
 	"github.com/a-h/templ"
@@ -19,34 +19,59 @@ import (
 	"github.com/pocketbase/pocketbase/daos"
 )
 
-const (
-	SeasonStatusInProgress = "inprogress"
-	SeasonStatusClosed     = "closed"
-	SeasonStatusScheduled  = "scheduled"
-)
-
 var (
 	seasonSVC service.Service
 	memberSVC service.Service
 	statSVC   service.Service
 	sportSVC  service.Service
+
+	seasonRepo service.Repo[models.Season]
+	memberRepo service.Repo[models.Member]
+	statRepo   service.Repo[models.MemberStat]
+	sportRepo  service.Repo[models.Sport]
+
+	historyRepo service.Repo[models.RecordHistory]
 )
 
 type GroupHandler struct {
-	svc service.Service
-	api pb.Client
+	svc    service.Service
+	repo   service.Repo[models.Group]
+	api    pb.Client
+	search searchIndex
 }
 
 func NewGroupHandler(db *daos.Dao, url string) *GroupHandler {
-	seasonSVC = service.NewService("seasons", "seasonid", db)
-	memberSVC = service.NewService("members", "memberid", db)
-	statSVC = service.NewService("memberstats", "statid", db)
+	statSVC = service.NewService("memberstats", "statid", db).WithRules(statRules...)
+	seasonSVC = service.NewService("seasons", "seasonid", db).WithRules(seasonRules...).WithDependents(
+		service.Dependent{Service: statSVC, Key: "season", OnDelete: service.Cascade, Label: "stats"},
+	)
+	memberSVC = service.NewService("members", "memberid", db).WithRules(memberRules...).WithDependents(
+		service.Dependent{Service: statSVC, Key: "member", OnDelete: service.Cascade, Label: "stats"},
+	)
 	sportSVC = service.NewService("sports", "sportid", db)
-	return &GroupHandler{svc: service.NewService("groups", "groupid", db), api: pb.New(url)}
+	seasonRepo = service.NewRepo[models.Season](seasonSVC)
+	memberRepo = service.NewRepo[models.Member](memberSVC)
+	statRepo = service.NewRepo[models.MemberStat](statSVC)
+	sportRepo = service.NewRepo[models.Sport](sportSVC)
+	registerHooks()
+	svc := service.NewService("groups", "groupid", db).WithRules(groupRules...).WithDependents(
+		service.Dependent{Service: seasonSVC, Key: "group", OnDelete: service.Cascade},
+		service.Dependent{Service: memberSVC, Key: "group", OnDelete: service.Cascade},
+		service.Dependent{Service: statSVC, Key: "group", OnDelete: service.Cascade, Label: "stats"},
+	)
+	history := service.NewHistory(db)
+	history.Track(historyScope, svc, seasonSVC, memberSVC, statSVC)
+	historyRepo = service.NewRepo[models.RecordHistory](service.NewService(service.HistoryCollection, "historyid", db))
+	search := newSearchIndex(db)
+	search.register(svc, memberSVC)
+	if err := search.Init(); err != nil {
+		xlog.Error("error while building the search index", "error", err)
+	}
+	return &GroupHandler{svc: svc, repo: service.NewRepo[models.Group](svc), api: pb.New(url), search: search}
 }
 
-func (h GroupHandler) GetGroup(id string) (service.Record, error) {
-	v, err := h.svc.GetByID(context.Background(), id, "user", "sport", "seasons_via_group")
+func (h GroupHandler) GetGroup(id string) (models.Group, error) {
+	v, err := h.repo.Get(context.Background(), id, "user", "sport", "seasons_via_group")
 	if err != nil {
 		xlog.Error("error while getting group", "group", id, "error", err)
 	}
@@ -54,72 +79,52 @@ func (h GroupHandler) GetGroup(id string) (service.Record, error) {
 }
 
 func (h GroupHandler) GetGroupSport(ctx context.Context, groupID string) models.Sport {
-	group, err := h.svc.GetByID(ctx, groupID, "sport")
+	group, err := h.repo.Get(ctx, groupID, "sport")
 	if err != nil {
 		return models.Sport{}
 	}
-	var s models.Sport
-	sport := group.Data.ExpandedOne("sport")
-	service.UnmarshalTo(sport, &s)
-	xlog.Debug("group's sport", "sport", s)
-	return s
+	xlog.Debug("group's sport", "sport", group.V().Expand.Sport)
+	return group.V().Expand.Sport
 }
 
-func (h GroupHandler) GetGroupCurrentSeason(ctx context.Context, groupID string) (service.Record, error) {
-	seasons, err := seasonSVC.List(ctx, map[string]any{"group": groupID})
+func (h GroupHandler) GetSports(ctx context.Context) (view.ViewData[[]models.Sport], error) {
+	sports, err := sportRepo.List(ctx, service.NewQuery(service.Filters{}, "name").WithPage(1, service.MaxPerPage))
 	if err != nil {
-		xlog.Error("failed to get group seasons", "group", groupID)
-		return seasonSVC.GetNewRecord(), err
-	}
-	sort.Slice(seasons.Data, func(i, j int) bool {
-		return seasons.Data[i].GetString("end_date") < seasons.Data[j].GetString("end_date")
-	})
-	var currentSeason = seasonSVC.GetNewRecord()
-	for _, season := range seasons.Data {
-		if strings.EqualFold(season.GetString("status"), SeasonStatusInProgress) {
-			currentSeason = season
-			break
-		}
-	}
-	if currentSeason == nil && len(seasons.Data) > 0 {
-		currentSeason = seasons.Data[0]
+		xlog.Error("failed to get sport list", "error", err)
+		return view.ViewData[[]models.Sport]{}, err
 	}
-	return currentSeason, nil
+	return view.NewViewData(sports.V().Items, sports.Errors), nil
+
 }
 
-func (h GroupHandler) GetSports(ctx context.Context) (view.ViewData[service.RecordSlice], error) {
-	sports, err := sportSVC.List(ctx, service.Filters{})
+func (h GroupHandler) renderGroupForm(ctx echo.Context, context context.Context, vd view.ViewData[models.Group], attr templ.Attributes) error {
+	sports, err := h.GetSports(context)
 	if err != nil {
-		xlog.Error("failed to get sport list", "error", err)
-		return view.ViewData[service.RecordSlice]{}, err
+		xlog.Error("error while getting sports", "error", err)
 	}
-	return sports, nil
-
+	return view.Render(ctx, http.StatusOK, GroupFormView(vd, sports, attr), nil)
 }
 
 func (h GroupHandler) Create(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		var (
-			err error
-			req = service.Request{}
+			err   error
+			group models.Group
 		)
+		attr := templ.Attributes{"target": "#content", "hx-post": reverse(ctx, "group.create")}
 		if ctx.Request().Method == http.MethodGet {
-			sports, err := h.GetSports(context)
-			if err != nil {
-				xlog.Error("error while getting sports", "error", err)
-			}
-			return view.Render(ctx, http.StatusOK, GroupFormView(
-				view.NewViewData(h.svc.GetNewRecord(), errorsmap.New()), sports,
-				templ.Attributes{"target": "#content", "hx-post": reverse(ctx, "group.create")}), nil)
+			return h.renderGroupForm(ctx, context, view.NewViewData(group, errorsmap.New()), attr)
 		}
-		if err = ctx.Bind(&req); err != nil {
+		if err = ctx.Bind(&group); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
+		// only the owner adds the managers, and a group is not created trashed
+		group.User, group.Managers, group.Deleted = xsession.GetUser(ctx.Request().Context()).ID, []string{}, ""
 
-		_, err = h.svc.Create(context, req)
+		vd, err := h.repo.Create(actor(ctx, context), group)
 		if err != nil {
 			xlog.Error("group-handler-create", "errors", err)
-			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+			return h.renderGroupForm(ctx, context, view.NewViewData(group, vd.Errors), attr)
 		}
 		return ctx.Redirect(http.StatusFound, view.ReverseX(ctx, "account.get", xsession.GetUser(ctx.Request().Context()).ID))
 	}
@@ -129,13 +134,12 @@ func (h GroupHandler) Create(context context.Context) echo.HandlerFunc {
 func (h GroupHandler) Get(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		id := ctx.PathParam(h.svc.GetID())
-		token := xsession.Get[string](ctx.Request().Context(), xsession.SessionName)
-		xlog.Debug("get", "group-id", id, "token", token)
-		resp, err := h.api.RecordGet("groups", id, pb.QHeaders{"Authorization": token}, pb.QExpand{"sport"})
+		api := h.api.WithAuth(xsession.AuthStore(ctx.Request().Context()))
+		xlog.Debug("get", "group-id", id)
+		group, err := pb.Collection[models.Group](&api, "groups").WithContext(ctx.Request().Context()).Get(id, pb.QExpand{"sport"})
 		if err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		group := pb.ResponseTo[models.Group](resp)
 		xlog.Debug("get group", "group", group)
 		return view.Render(ctx, http.StatusSeeOther, GroupDetailView(group), nil)
 	}
@@ -144,23 +148,27 @@ func (h GroupHandler) Get(context context.Context) echo.HandlerFunc {
 func (h GroupHandler) Update(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		id := ctx.PathParam(h.svc.GetID())
-		group, _ := h.GetGroup(id)
+		group, err := h.GetGroup(id)
+		if err != nil {
+			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+		}
+		attr := templ.Attributes{"target": "#content", "hx-patch": reverse(ctx, "group.update", id)}
 		if ctx.Request().Method == http.MethodGet {
-			sports, err := h.GetSports(context)
-			if err != nil {
-				xlog.Error("error while getting sports", "error", err)
-			}
-			return view.Render(ctx, http.StatusOK, GroupFormView(
-				view.NewViewData(group, errorsmap.New()), sports,
-				templ.Attributes{"target": "#content", "hx-patch": reverse(ctx, "group.update", id)}), nil)
+			return h.renderGroupForm(ctx, context, view.NewViewData(group, errorsmap.New()), attr)
 		}
-		req := service.Request{"id": id}
-		if err := ctx.Bind(&req); err != nil {
+		// Only the owner manages the managers and the trash, the stored
+		// values win over the bound ones.
+		stored := group
+		if err := ctx.Bind(&group); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		_, err := h.svc.Update(context, req)
-		if err != nil {
-			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+		group.ID, group.User, group.Managers, group.Deleted = id, stored.User, stored.Managers, stored.Deleted
+		if vd, err := h.repo.Update(actor(ctx, context), group); err != nil {
+			if errors.Is(err, service.ErrConflict) {
+				current, _ := h.GetGroup(id)
+				return h.renderGroupForm(ctx, context, view.NewViewData(current, vd.Errors), attr)
+			}
+			return h.renderGroupForm(ctx, context, view.NewViewData(group, vd.Errors), attr)
 		}
 		return ctx.Redirect(http.StatusSeeOther, view.ReverseX(ctx, "account.get", xsession.GetUser(ctx.Request().Context()).ID))
 	}
@@ -168,12 +176,16 @@ func (h GroupHandler) Update(context context.Context) echo.HandlerFunc {
 
 func (h GroupHandler) List(context context.Context) echo.HandlerFunc {
 	return func(c echo.Context) error {
-		var filters = make(map[string]any)
-		if city := c.QueryParam("search"); !strings.EqualFold(city, "") {
-			filters["city"] = city
-			xlog.Debug("filters are", "filters", filters, "city", city)
+		var (
+			resp view.ViewData[models.Collection[models.Group]]
+			err  error
+			q    = service.ParseQuery(c.QueryParams(), "name")
+		)
+		if text := c.QueryParam("search"); !strings.EqualFold(text, "") {
+			resp, err = h.searchGroups(context, text, q)
+		} else {
+			resp, err = h.repo.List(context, q, "sport")
 		}
-		resp, err := h.svc.List(context, filters, "sport")
 		if err != nil {
 			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
 		}
@@ -181,10 +193,28 @@ func (h GroupHandler) List(context context.Context) echo.HandlerFunc {
 	}
 }
 
+// renderDeleteDialog renders the DeleteDialog of the record id of svc,
+// url being its delete route.
+func renderDeleteDialog(ctx echo.Context, context context.Context, svc service.Service, id, what, url string) error {
+	affected, err := svc.Affected(context, id)
+	if err != nil {
+		xlog.Error("error while counting dependents", "collection", svc.Name, "record", id, "error", err)
+		return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+	}
+	return view.Render(ctx, http.StatusOK, DeleteDialog(what, affected, url), nil)
+}
+
+func (h GroupHandler) DeleteConfirm(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		id := ctx.PathParam(h.svc.GetID())
+		return renderDeleteDialog(ctx, context, h.svc, id, "group", reverse(ctx, "group.delete", id))
+	}
+}
+
 func (h GroupHandler) Delete(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		id := ctx.PathParam(h.svc.GetID())
-		if err := h.svc.Delete(context, id); err != nil {
+		if err := h.repo.Delete(actor(ctx, context), id); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
 		return ctx.Redirect(http.StatusSeeOther, view.ReverseX(ctx, "account.get", xsession.GetUser(ctx.Request().Context()).ID))
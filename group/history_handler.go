@@ -0,0 +1,160 @@
+package group
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/josuebrunel/sportdropin/pkg/authz"
+	"github.com/josuebrunel/sportdropin/pkg/models"
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/josuebrunel/sportdropin/pkg/view"
+	"github.com/josuebrunel/sportdropin/pkg/view/component"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/labstack/echo/v5"
+)
+
+// timeline is the history of a record, latest change first.
+type timeline struct {
+	Collection string
+	Record     string
+	Label      string
+	Entries    []models.RecordHistory
+}
+
+// historyScope lists the history of the groups and of their seasons,
+// members and stats under the group.
+func historyScope(r service.Record) string {
+	if r.Collection().Name == "groups" {
+		return r.Id
+	}
+	return r.GetString("group")
+}
+
+// historyServices returns the tracked services by collection name.
+func (h GroupHandler) historyServices() map[string]service.Service {
+	return map[string]service.Service{
+		h.svc.Name:     h.svc,
+		seasonSVC.Name: seasonSVC,
+		memberSVC.Name: memberSVC,
+		statSVC.Name:   statSVC,
+	}
+}
+
+// timelines groups the entries, latest first, by record.
+func timelines(entries []models.RecordHistory) []*timeline {
+	tt := []*timeline{}
+	byRecord := map[string]*timeline{}
+	for _, e := range entries {
+		t, ok := byRecord[e.Record]
+		if !ok {
+			t = &timeline{Collection: e.Collection, Record: e.Record, Label: e.Record}
+			byRecord[e.Record] = t
+			tt = append(tt, t)
+		}
+		t.Entries = append(t.Entries, e)
+		if label := historyLabel(e); t.Label == e.Record && label != "" {
+			t.Label = label
+		}
+	}
+	return tt
+}
+
+// historyLabel returns the name of the record of e, if changed by e.
+func historyLabel(e models.RecordHistory) string {
+	for _, f := range []string{"name", "username"} {
+		if c, ok := e.Diff[f]; ok {
+			if c.New != nil {
+				return historyValue(c.New)
+			}
+			return historyValue(c.Old)
+		}
+	}
+	return ""
+}
+
+// historyValue formats a value of a history diff.
+func historyValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func (h GroupHandler) renderHistory(ctx echo.Context, context context.Context, groupID string) error {
+	q := service.ParseQuery(ctx.QueryParams(), "-created").WithFilters(service.Filters{"scope": groupID})
+	entries, err := historyRepo.List(context, q, "actor")
+	if err != nil {
+		xlog.Error("error while getting history", "group", groupID, "error", err)
+		return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+	}
+	return view.Render(ctx, http.StatusOK, GroupHistoryView(groupID, timelines(entries.V().Items), entries.V()), nil)
+}
+
+func (h GroupHandler) History(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return h.renderHistory(ctx, context, ctx.PathParam(h.svc.GetID()))
+	}
+}
+
+// revertable lists by collection the fields a history entry can set back,
+// the ones of the edit forms, with the kind of resource and the action the
+// session user must be allowed to run on it.
+var revertable = map[string]struct {
+	kind   string
+	action authz.Action
+	fields []string
+}{
+	"groups":      {authz.Group, authz.Update, []string{"name", "description", "sport", "street", "city", "country"}},
+	"seasons":     {authz.Season, authz.Update, []string{"name", "status", "start_date", "end_date"}},
+	"members":     {authz.Member, authz.Update, []string{"username", "email", "phone"}},
+	"memberstats": {authz.Stat, authz.Create, []string{"stats"}},
+}
+
+// canRevert reports whether field of the history entry e can be set back,
+// only the updates being revertable.
+func canRevert(e models.RecordHistory, field string) bool {
+	r, ok := revertable[e.Collection]
+	return ok && e.Op == string(service.OpUpdate) && slices.Contains(r.fields, field)
+}
+
+// HistoryRevert sets the field form value of a record back to its value
+// before the change of the history entry. The session user must be
+// allowed to edit the record.
+func (h GroupHandler) HistoryRevert(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		groupID := ctx.PathParam(h.svc.GetID())
+		vd, err := historyRepo.Get(context, ctx.PathParam(historyRepo.GetID()))
+		entry := vd.V()
+		if err != nil || entry.Scope != groupID {
+			return view.Render(ctx, http.StatusNotFound, component.Error(ErrNotFound.Error()), nil)
+		}
+		field := ctx.FormValue("field")
+		change, ok := entry.Diff[field]
+		svc, tracked := h.historyServices()[entry.Collection]
+		if !ok || !tracked || !canRevert(entry, field) {
+			return view.Render(ctx, http.StatusOK, component.Error(fmt.Sprintf("%q can't be reverted", field)), nil)
+		}
+		group, err := h.GetGroup(groupID)
+		if err != nil {
+			return view.Render(ctx, http.StatusNotFound, component.Error(ErrNotFound.Error()), nil)
+		}
+		if r := revertable[entry.Collection]; !can(ctx.Request().Context(), r.action, r.kind, group) {
+			return view.Render(ctx, http.StatusForbidden, component.Error(authz.ErrForbidden.Error()), nil)
+		}
+		if _, err := svc.Update(actor(ctx, context), service.Request{svc.ID: entry.Record, field: change.Old}); err != nil {
+			xlog.Error("error while reverting", "collection", entry.Collection, "record", entry.Record, "field", field, "error", err)
+			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+		}
+		return h.renderHistory(ctx, context, groupID)
+	}
+}
@@ -0,0 +1,37 @@
+package group
+
+import (
+	"context"
+
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/josuebrunel/sportdropin/pkg/xsession"
+	"github.com/labstack/echo/v5"
+)
+
+// actor returns cx holding the session user, passed on to the service hooks.
+func actor(ctx echo.Context, cx context.Context) context.Context {
+	return service.WithActor(cx, xsession.GetUser(ctx.Request().Context()).ID)
+}
+
+// registerHooks keeps an audit trail of the group activity.
+func registerHooks() {
+	memberSVC.OnAfterCreate().Add(func(e *service.RecordEvent) error {
+		xlog.Info("member added", "group", e.New.GetString("group"), "member", e.New.Id, "actor", e.Actor)
+		return nil
+	})
+	seasonSVC.OnAfterUpdate().Add(func(e *service.RecordEvent) error {
+		if e.Old.GetString("status") != SeasonStatusClosed && e.New.GetString("status") == SeasonStatusClosed {
+			xlog.Info("season closed", "group", e.New.GetString("group"), "season", e.New.Id, "actor", e.Actor)
+		}
+		return nil
+	})
+	statsSaved := func(e *service.RecordEvent) error {
+		xlog.Info("stats saved", "season", e.New.GetString("season"), "member", e.New.GetString("member"), "actor", e.Actor)
+		return nil
+	}
+	seasonSVC.OnBeforeCreate().Add(oneSeasonInProgress)
+	seasonSVC.OnBeforeUpdate().Add(oneSeasonInProgress)
+	statSVC.OnAfterCreate().Add(statsSaved)
+	statSVC.OnAfterUpdate().Add(statsSaved)
+}
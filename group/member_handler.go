@@ -2,10 +2,12 @@ package group
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/a-h/templ"
 	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	"github.com/josuebrunel/sportdropin/pkg/models"
 	"github.com/josuebrunel/sportdropin/pkg/service"
 	"github.com/josuebrunel/sportdropin/pkg/view"
 	"github.com/josuebrunel/sportdropin/pkg/view/component"
@@ -19,21 +21,26 @@ func (h GroupHandler) MemberCreate(context context.Context) echo.HandlerFunc {
 		if ctx.Request().Method == http.MethodGet {
 			return view.Render(ctx, http.StatusOK,
 				GroupMemberForm(
-					view.NewViewData(memberSVC.GetNewRecord(), errorsmap.New()),
+					view.NewViewData(models.Member{Group: groupID}, errorsmap.New()),
 					templ.Attributes{"hx-post": ctx.RouteInfo().Reverse(groupID)}),
 				nil)
 		}
-		req := service.Request{}
-		if err := ctx.Bind(&req); err != nil {
+		var member models.Member
+		if err := ctx.Bind(&member); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		req["group"] = groupID
-		_, err := memberSVC.Create(context, req)
+		member.Group = groupID
+		vd, err := memberRepo.Create(actor(ctx, context), member)
 		if err != nil {
-			xlog.Error("error while creating member", "req", req, "error", err)
-			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+			xlog.Error("error while creating member", "member", member, "error", err)
+			view.Retarget(ctx, "closest tr", "outerHTML")
+			return view.Render(ctx, http.StatusOK,
+				GroupMemberForm(
+					view.NewViewData(member, vd.Errors),
+					templ.Attributes{"hx-post": ctx.RouteInfo().Reverse(groupID)}),
+				nil)
 		}
-		members, err := memberSVC.List(context, map[string]any{"group": groupID})
+		members, err := memberRepo.List(context, service.NewQuery(service.Filters{"group": groupID}, "username"))
 		if err != nil {
 			xlog.Error("error while getting members", "group", groupID, "error", err)
 		}
@@ -45,7 +52,8 @@ func (h GroupHandler) MemberCreate(context context.Context) echo.HandlerFunc {
 func (h GroupHandler) MemberList(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		groupID := ctx.PathParam(h.svc.GetID())
-		members, err := memberSVC.List(context, map[string]any{"group": groupID})
+		q := service.ParseQuery(ctx.QueryParams(), "username").WithFilters(service.Filters{"group": groupID})
+		members, err := memberRepo.List(context, q)
 		if err != nil {
 			xlog.Error("error while getting members", "group", groupID, "error", err)
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
@@ -59,25 +67,35 @@ func (h GroupHandler) MemberEdit(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		groupID := ctx.PathParam(h.svc.GetID())
 		memberID := ctx.PathParam(memberSVC.GetID())
-		vd, _ := memberSVC.GetByID(context, memberID)
+		vd, _ := memberRepo.Get(context, memberID)
 		if ctx.Request().Method == http.MethodGet {
 			xlog.Debug("member", "member", vd)
 			return view.Render(ctx, http.StatusOK,
 				GroupMemberForm(vd, templ.Attributes{"hx-patch": ctx.RouteInfo().Reverse(groupID, memberID)}),
 				nil)
 		}
-		req := service.Request{}
-		if err := ctx.Bind(&req); err != nil {
+		member := vd.V()
+		deleted := member.Deleted
+		if err := ctx.Bind(&member); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		req["id"] = memberID
-		req["group"] = groupID
-		_, err := memberSVC.Update(context, req)
-		if err != nil {
-			xlog.Error("error while creating member", "req", req, "error", err)
-			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+		member.Deleted = deleted
+		member.ID = memberID
+		member.Group = groupID
+		if vd, err := memberRepo.Update(actor(ctx, context), member); err != nil {
+			xlog.Error("error while creating member", "member", member, "error", err)
+			if errors.Is(err, service.ErrConflict) {
+				current, _ := memberRepo.Get(context, memberID)
+				member = current.V()
+			}
+			view.Retarget(ctx, "closest tr", "outerHTML")
+			return view.Render(ctx, http.StatusOK,
+				GroupMemberForm(
+					view.NewViewData(member, vd.Errors),
+					templ.Attributes{"hx-patch": ctx.RouteInfo().Reverse(groupID, memberID)}),
+				nil)
 		}
-		members, err := memberSVC.List(context, map[string]any{"group": groupID})
+		members, err := memberRepo.List(context, service.NewQuery(service.Filters{"group": groupID}, "username"))
 		if err != nil {
 			xlog.Error("error while getting members", "group", groupID, "error", err)
 		}
@@ -86,15 +104,23 @@ func (h GroupHandler) MemberEdit(context context.Context) echo.HandlerFunc {
 	}
 }
 
+func (h GroupHandler) MemberDeleteConfirm(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		groupID := ctx.PathParam(h.svc.GetID())
+		memberID := ctx.PathParam(memberSVC.GetID())
+		return renderDeleteDialog(ctx, context, memberSVC, memberID, "member", reverse(ctx, "member.delete", groupID, memberID))
+	}
+}
+
 func (h GroupHandler) MemberDelete(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		groupID := ctx.PathParam(h.svc.GetID())
 		memberID := ctx.PathParam(memberSVC.GetID())
-		if err := memberSVC.Delete(context, memberID); err != nil {
+		if err := memberRepo.Delete(actor(ctx, context), memberID); err != nil {
 			xlog.Error("error while deleting member", "member", memberID, "error", err)
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		members, err := memberSVC.List(context, map[string]any{"group": groupID})
+		members, err := memberRepo.List(context, service.NewQuery(service.Filters{"group": groupID}, "username"))
 		if err != nil {
 			xlog.Error("error while getting members", "group", groupID, "error", err)
 		}
@@ -0,0 +1,50 @@
+package group
+
+import (
+	"regexp"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/josuebrunel/sportdropin/pkg/service"
+)
+
+const MaxStatValue = 1_000_000
+
+var phoneRegex = regexp.MustCompile(`^\+?[0-9][0-9 ().-]{5,19}$`)
+
+// statValue validates a stored stat value, a boolean or a number.
+var statValue = validation.By(func(v any) error {
+	if _, ok := v.(bool); ok {
+		return nil
+	}
+	return validation.Validate(v, service.Number(0, MaxStatValue))
+})
+
+var (
+	groupRules = []service.Rule{
+		service.Field("name", validation.Required, validation.Length(3, 100)),
+		service.Field("sport", validation.Required),
+		service.Field("street", validation.Required, validation.Length(1, 255)),
+		service.Field("city", validation.Required, validation.Length(1, 100)),
+		service.Field("country", validation.Required, validation.Length(1, 100)),
+		service.Field("description", validation.Length(0, 2000)),
+	}
+	seasonRules = []service.Rule{
+		service.Field("name", validation.Required, validation.Length(1, 100)),
+		service.Field("status", validation.In(SeasonStatusScheduled, SeasonStatusInProgress, SeasonStatusClosed)),
+		service.Field("start_date", validation.Required),
+		service.Field("end_date", validation.Required),
+		service.DateOrder("start_date", "end_date"),
+		seasonTransition,
+	}
+	memberRules = []service.Rule{
+		service.Field("username", validation.Required, validation.Length(1, 50)),
+		service.Field("email", is.EmailFormat),
+		service.Field("phone", validation.Match(phoneRegex).Error("must be a valid phone number")),
+	}
+	statRules = []service.Rule{
+		service.Field("member", validation.Required),
+		service.Field("season", validation.Required),
+		service.Values("stats", statValue),
+	}
+)
@@ -0,0 +1,300 @@
+package group
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/josuebrunel/sportdropin/pkg/collection"
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	"github.com/josuebrunel/sportdropin/pkg/models"
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/josuebrunel/sportdropin/pkg/view"
+	"github.com/josuebrunel/sportdropin/pkg/view/component"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/labstack/echo/v5"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+const searchTable = "groups_fts"
+
+// searchRank orders the matches by bm25, weighting the searchTable columns:
+// a match on the name ranks first, then the place, the sport and the
+// members.
+const searchRank = "bm25(" + searchTable + ", 0, 10, 1, 5, 5, 3, 2)"
+
+var searchTermRegex = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// searchVersion is the version of the searchTable schema and content. Bump
+// it when changing them so the index is built again at startup.
+const searchVersion = 2
+
+// searchVersionParam is the PocketBase param holding the searchVersion the
+// index was built with.
+const searchVersionParam = searchTable + "_version"
+
+// searchIndex is a SQLite FTS5 index of the groups over their name,
+// description, city, country, sport name and member usernames. Accents are
+// folded and the terms of a search are matched as prefixes. It's kept in
+// sync by the group and member service hooks and by SyncSport.
+type searchIndex struct {
+	db *daos.Dao
+}
+
+// searchDoc is a row of the searchTable.
+type searchDoc struct {
+	ID          string `db:"id"`
+	Name        string `db:"name"`
+	Description string `db:"description"`
+	City        string `db:"city"`
+	Country     string `db:"country"`
+	Sport       string `db:"sport"`
+	Members     string `db:"members"`
+}
+
+func newSearchIndex(db *daos.Dao) searchIndex {
+	return searchIndex{db: db}
+}
+
+// Init creates the index, building it when it's missing or was built with
+// another searchVersion.
+func (idx searchIndex) Init() error {
+	if idx.upToDate() {
+		return nil
+	}
+	return idx.db.RunInTransaction(func(tx *daos.Dao) error {
+		idx := searchIndex{db: tx}
+		if idx.exists() {
+			if _, err := tx.DB().DropTable(searchTable).Execute(); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.DB().NewQuery(`CREATE VIRTUAL TABLE ` + searchTable + ` USING fts5(
+			id UNINDEXED, name, description, city, country, sport, members,
+			tokenize = 'unicode61 remove_diacritics 2',
+			prefix = '2 3'
+		)`).Execute(); err != nil {
+			return err
+		}
+		if err := idx.index(nil); err != nil {
+			return err
+		}
+		return tx.SaveParam(searchVersionParam, searchVersion)
+	})
+}
+
+func (idx searchIndex) exists() bool {
+	n := 0
+	err := idx.db.DB().Select("count(*)").From("sqlite_master").Where(dbx.HashExp{"name": searchTable}).Row(&n)
+	return err == nil && n > 0
+}
+
+func (idx searchIndex) upToDate() bool {
+	p, err := idx.db.FindParamByKey(searchVersionParam)
+	if err != nil {
+		return false
+	}
+	var version int
+	return json.Unmarshal(p.Value, &version) == nil && version == searchVersion && idx.exists()
+}
+
+// Sync indexes the group id again, removing it when it's deleted or
+// trashed.
+func (idx searchIndex) Sync(id string) error {
+	if _, err := idx.db.DB().Delete(searchTable, dbx.HashExp{"id": id}).Execute(); err != nil {
+		return err
+	}
+	return idx.index(dbx.HashExp{"g.id": id})
+}
+
+// SyncSport indexes again the groups of the sport id, whose name may have
+// changed.
+func (idx searchIndex) SyncSport(id string) error {
+	ids := []string{}
+	if err := idx.db.DB().Select("id").From("groups").Where(dbx.HashExp{"sport": id}).Column(&ids); err != nil || len(ids) == 0 {
+		return err
+	}
+	in := collection.Transform(ids, func(id string) any { return id })
+	if _, err := idx.db.DB().Delete(searchTable, dbx.In("id", in...)).Execute(); err != nil {
+		return err
+	}
+	return idx.index(dbx.HashExp{"g.sport": id})
+}
+
+// index inserts the live groups matching where, all of them when where is
+// nil. The members of the groups are fetched at once.
+func (idx searchIndex) index(where dbx.Expression) error {
+	docs := []searchDoc{}
+	err := idx.db.DB().
+		Select("g.id", "g.name", "g.description", "g.city", "g.country", "COALESCE(s.name, '') AS sport").
+		From("groups g").
+		LeftJoin("sports s", dbx.NewExp("[[s.id]] = [[g.sport]]")).
+		Where(idx.liveExp("groups", "g")).
+		AndWhere(where).
+		All(&docs)
+	if err != nil || len(docs) == 0 {
+		return err
+	}
+	ids := collection.Transform(docs, func(d searchDoc) any { return d.ID })
+	rows := []struct {
+		Group    string `db:"group"`
+		Username string `db:"username"`
+	}{}
+	err = idx.db.DB().
+		Select("m.group", "m.username").
+		From("members m").
+		Where(dbx.In("m.group", ids...)).
+		AndWhere(idx.liveExp("members", "m")).
+		OrderBy("m.username").
+		All(&rows)
+	if err != nil {
+		return err
+	}
+	members := map[string][]string{}
+	for _, r := range rows {
+		members[r.Group] = append(members[r.Group], r.Username)
+	}
+	for _, d := range docs {
+		d.Members = strings.Join(members[d.ID], " ")
+		_, err := idx.db.DB().Insert(searchTable, dbx.Params{
+			"id":          d.ID,
+			"name":        d.Name,
+			"description": d.Description,
+			"city":        d.City,
+			"country":     d.Country,
+			"sport":       d.Sport,
+			"members":     d.Members,
+		}).Execute()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liveExp leaves out the trashed records of the collection name aliased
+// as alias, if it has a trash.
+func (idx searchIndex) liveExp(name, alias string) dbx.Expression {
+	if c, err := idx.db.FindCollectionByNameOrId(name); err == nil && c.Schema.GetFieldByName(service.DeletedField) != nil {
+		return service.Live(alias + "." + service.DeletedField)
+	}
+	return nil
+}
+
+// OnSportUpdate indexes again the groups of the updated sport, the sports
+// being edited from the PocketBase dashboard.
+func (h GroupHandler) OnSportUpdate(e *core.ModelEvent) error {
+	if err := (searchIndex{db: e.Dao}).SyncSport(e.Model.GetId()); err != nil {
+		xlog.Error("error while indexing the groups of sport", "sport", e.Model.GetId(), "error", err)
+	}
+	return nil
+}
+
+// match turns the text typed by a user into a FTS5 query matching the
+// records holding a word starting with each of its terms. It's empty when
+// text has no term.
+func match(text string) string {
+	terms := searchTermRegex.FindAllString(text, -1)
+	for i, t := range terms {
+		terms[i] = `"` + t + `"*`
+	}
+	return strings.Join(terms, " ")
+}
+
+// register keeps the index in sync with the groups and members saved
+// through their services, in the transaction of the operation.
+func (idx searchIndex) register(groups, members service.Service) {
+	syncGroup := func(e *service.RecordEvent) error {
+		return searchIndex{db: e.Dao}.Sync(e.Record().Id)
+	}
+	groups.OnAfterCreate().Add(syncGroup)
+	groups.OnAfterUpdate().Add(syncGroup)
+	groups.OnAfterDelete().Add(syncGroup)
+
+	syncMember := func(e *service.RecordEvent) error {
+		ids := map[string]bool{}
+		for _, r := range []service.Record{e.Old, e.New} {
+			if r != nil {
+				ids[r.GetString("group")] = true
+			}
+		}
+		for id := range ids {
+			if err := (searchIndex{db: e.Dao}).Sync(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	members.OnAfterCreate().Add(syncMember)
+	members.OnAfterUpdate().Add(syncMember)
+	members.OnAfterDelete().Add(syncMember)
+}
+
+// Search returns the ids of a page of the groups matching text, best match
+// first, and the total of groups matching.
+func (idx searchIndex) Search(ctx context.Context, text string, page, perPage int) ([]any, int, error) {
+	ids, total := []any{}, 0
+	m := match(text)
+	if m == "" {
+		return ids, total, nil
+	}
+	where := dbx.NewExp(searchTable+" MATCH {:match}", dbx.Params{"match": m})
+	err := idx.db.DB().Select("count(*)").From(searchTable).Where(where).WithContext(ctx).Row(&total)
+	if err != nil {
+		return ids, total, err
+	}
+	found := []string{}
+	err = idx.db.DB().Select("id").
+		From(searchTable).
+		Where(where).
+		OrderBy(searchRank).
+		Limit(int64(perPage)).
+		Offset(int64((page - 1) * perPage)).
+		WithContext(ctx).
+		Column(&found)
+	for _, id := range found {
+		ids = append(ids, id)
+	}
+	return ids, total, err
+}
+
+// searchGroups lists the page of q of the groups matching text, best match
+// first.
+func (h GroupHandler) searchGroups(ctx context.Context, text string, q service.Query) (view.ViewData[models.Collection[models.Group]], error) {
+	q = q.Normalize()
+	ids, total, err := h.search.Search(ctx, text, q.Page, q.PerPage)
+	if err != nil {
+		xlog.Error("error while searching groups", "search", text, "error", err)
+		return view.NewViewData(models.Collection[models.Group]{}, errorsmap.EMap{"error": err}), err
+	}
+	vd, err := h.repo.List(ctx, service.NewQuery(service.Filters{"id": service.In(ids)}).WithPage(1, len(ids)), "sport")
+	if err != nil {
+		return vd, err
+	}
+	rank := map[string]int{}
+	for i, id := range ids {
+		rank[id.(string)] = i
+	}
+	page := vd.V()
+	sort.SliceStable(page.Items, func(i, j int) bool { return rank[page.Items[i].ID] < rank[page.Items[j].ID] })
+	page.Page, page.PerPage, page.TotalItems = q.Page, q.PerPage, total
+	page.TotalPages = (total + q.PerPage - 1) / q.PerPage
+	return view.NewViewData(page, vd.Errors), nil
+}
+
+// Suggest renders the names of the groups best matching the search param
+// as the options of the search bar datalist.
+func (h GroupHandler) Suggest(context context.Context) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		groups, err := h.searchGroups(context, c.QueryParam("search"), service.NewQuery(nil).WithPage(1, 8))
+		if err != nil {
+			return view.Render(c, http.StatusOK, component.Error(err.Error()), nil)
+		}
+		return view.Render(c, http.StatusOK, GroupSuggestions(groups.V().Items), nil)
+	}
+}
@@ -0,0 +1,177 @@
+// The PocketBase collections can't be decoded with the encoding/json v2
+// experiment, see pkg/service/service_test.go.
+
+//go:build !goexperiment.jsonv2
+
+package group
+
+import (
+	"context"
+	"testing"
+
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/migrate"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// newTestDao returns a dao over a fresh database holding the collections of
+// the groups.
+func newTestDao(t *testing.T) *daos.Dao {
+	t.Helper()
+	app := core.NewBaseApp(core.BaseAppConfig{DataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+	runner, err := migrate.NewRunner(app.DB(), migrations.AppMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(); err != nil {
+		t.Fatal(err)
+	}
+	dao := app.Dao()
+	text := func(name string) *schema.SchemaField {
+		return &schema.SchemaField{Name: name, Type: schema.FieldTypeText}
+	}
+	relation := func(name string, to *models.Collection) *schema.SchemaField {
+		return &schema.SchemaField{Name: name, Type: schema.FieldTypeRelation, Options: &schema.RelationOptions{
+			CollectionId: to.Id,
+			MaxSelect:    types.Pointer(1),
+		}}
+	}
+	date := func(name string) *schema.SchemaField {
+		return &schema.SchemaField{Name: name, Type: schema.FieldTypeDate}
+	}
+	deleted := date(service.DeletedField)
+	collection := func(name string, fields ...*schema.SchemaField) *models.Collection {
+		c := &models.Collection{Name: name, Type: models.CollectionTypeBase, Schema: schema.NewSchema(fields...)}
+		if err := dao.SaveCollection(c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	sports := collection("sports", text("name"))
+	groups := collection("groups", text("name"), text("description"), text("city"), text("country"), relation("sport", sports), deleted)
+	seasons := collection("seasons", relation("group", groups), text("name"), text("status"), date("start_date"), date("end_date"), deleted)
+	members := collection("members", relation("group", groups), text("username"), deleted)
+	collection("memberstats", relation("group", groups), relation("member", members), relation("season", seasons),
+		&schema.SchemaField{Name: "stats", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 1 << 16}})
+	return dao
+}
+
+// requireFTS5 fails the test without SQLite FTS5, the groups being
+// written along their search index.
+func requireFTS5(t *testing.T, dao *daos.Dao) {
+	t.Helper()
+	if _, err := dao.DB().NewQuery("CREATE VIRTUAL TABLE temp.fts5_probe USING fts5(x)").Execute(); err != nil {
+		t.Fatal("the search index needs SQLite FTS5, run the tests with -tags sqlite_fts5:", err)
+	}
+}
+
+func saveRecord(t *testing.T, dao *daos.Dao, collection string, data map[string]any) *models.Record {
+	t.Helper()
+	c, err := dao.FindCollectionByNameOrId(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := models.NewRecord(c)
+	r.Load(data)
+	if err := dao.SaveRecord(r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// search returns the ids of the groups matching text.
+func search(t *testing.T, idx searchIndex, text string) []any {
+	t.Helper()
+	ids, _, err := idx.Search(context.Background(), text, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestSearchIndex(t *testing.T) {
+	dao := newTestDao(t)
+	requireFTS5(t, dao)
+	sport := saveRecord(t, dao, "sports", map[string]any{"name": "Soccer"})
+	lions := saveRecord(t, dao, "groups", map[string]any{"name": "Lions", "city": "Paris", "sport": sport.Id})
+	saveRecord(t, dao, "groups", map[string]any{"name": "Bears", "city": "Paris", service.DeletedField: types.NowDateTime()})
+	saveRecord(t, dao, "members", map[string]any{"group": lions.Id, "username": "zidane"})
+	saveRecord(t, dao, "members", map[string]any{"group": lions.Id, "username": "henry", service.DeletedField: types.NowDateTime()})
+	idx := newSearchIndex(dao)
+	if err := idx.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"paris", 1},
+		{"socc", 1},
+		{"zidane", 1},
+		{"henry", 0},
+		{"bears", 0},
+	}
+	for _, tt := range tests {
+		if got := search(t, idx, tt.text); len(got) != tt.want {
+			t.Errorf("%q: got %v, want %d groups", tt.text, got, tt.want)
+		}
+	}
+
+	sport.Set("name", "Football")
+	if err := dao.SaveRecord(sport); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.SyncSport(sport.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(t, idx, "football"); len(got) != 1 || got[0] != lions.Id {
+		t.Errorf("renamed sport: got %v, want [%s]", got, lions.Id)
+	}
+	if got := search(t, idx, "soccer"); len(got) != 0 {
+		t.Errorf("former sport name: got %v", got)
+	}
+}
+
+func TestSearchIndexInit(t *testing.T) {
+	dao := newTestDao(t)
+	requireFTS5(t, dao)
+	saveRecord(t, dao, "groups", map[string]any{"name": "Lions"})
+	idx := newSearchIndex(dao)
+	if err := idx.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// left as is by the next starts
+	if _, err := dao.DB().Insert(searchTable, dbx.Params{"id": "stale", "name": "Stale"}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(t, idx, "stale"); len(got) != 1 {
+		t.Fatalf("up to date index built again, got %v", got)
+	}
+
+	if err := dao.SaveParam(searchVersionParam, searchVersion-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(t, idx, "stale"); len(got) != 0 {
+		t.Errorf("outdated index not built again, got %v", got)
+	}
+	if got := search(t, idx, "lions"); len(got) != 1 {
+		t.Errorf("got %v, want the group", got)
+	}
+}
@@ -0,0 +1,162 @@
+package group
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/josuebrunel/sportdropin/pkg/models"
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+)
+
+// A season is scheduled, then in progress and finally closed. A scheduled
+// season may be closed without having run, i.e. when its dates went by
+// while another season of the group was in progress.
+const (
+	SeasonStatusScheduled  = "scheduled"
+	SeasonStatusInProgress = "inprogress"
+	SeasonStatusClosed     = "closed"
+)
+
+var seasonTransitions = map[string][]string{
+	SeasonStatusScheduled:  {SeasonStatusInProgress, SeasonStatusClosed},
+	SeasonStatusInProgress: {SeasonStatusClosed},
+	SeasonStatusClosed:     {},
+}
+
+var seasonStatusLabels = map[string]string{
+	SeasonStatusScheduled:  "Scheduled",
+	SeasonStatusInProgress: "In progress",
+	SeasonStatusClosed:     "Closed",
+}
+
+// canTransition reports whether a season may go from the status from to
+// the status to.
+func canTransition(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, s := range seasonTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// seasonStatusOptions returns the options of the status select of a season
+// being in status: the status itself and the ones it may go to. A new
+// season may start in any status.
+func seasonStatusOptions(status string) map[string]string {
+	options := map[string]string{}
+	for s, label := range seasonStatusLabels {
+		if status == "" || canTransition(status, s) {
+			options[label] = s
+		}
+	}
+	return options
+}
+
+// seasonTransition checks the status change of a saved season.
+func seasonTransition(r service.Record) error {
+	if r.IsNew() {
+		return nil
+	}
+	from, to := r.OriginalCopy().GetString("status"), r.GetString("status")
+	if from == "" || canTransition(from, to) {
+		return nil
+	}
+	return validation.Errors{"status": fmt.Errorf("a %s season can't be %s", from, to)}
+}
+
+// oneSeasonInProgress vetoes saving a season in progress while the group
+// has another one.
+func oneSeasonInProgress(e *service.RecordEvent) error {
+	if e.New.GetString("status") != SeasonStatusInProgress {
+		return nil
+	}
+	q := service.NewQuery(service.Filters{"group": e.New.GetString("group"), "status": SeasonStatusInProgress})
+	seasons, err := service.NewRepo[models.Season](seasonSVC.WithDao(e.Dao)).List(e.Context, q)
+	if err != nil {
+		return err
+	}
+	for _, s := range seasons.V().Items {
+		if s.ID != e.New.Id {
+			return validation.Errors{"status": fmt.Errorf("%s is already in progress", s.Name)}
+		}
+	}
+	return nil
+}
+
+// seasonEnded reports whether the last day of s is over at now.
+func seasonEnded(s models.Season, now time.Time) bool {
+	return !s.EndDate.IsZero() && !now.Before(s.EndDate.Truncate(24*time.Hour).AddDate(0, 0, 1))
+}
+
+func (h GroupHandler) GetGroupCurrentSeason(ctx context.Context, groupID string) (models.Season, error) {
+	q := service.NewQuery(service.Filters{"group": groupID, "status": SeasonStatusInProgress}, "-start_date").WithPage(1, 1)
+	seasons, err := seasonRepo.List(ctx, q)
+	if err != nil {
+		xlog.Error("failed to get group seasons", "group", groupID)
+		return models.Season{}, err
+	}
+	if items := seasons.V().Items; len(items) > 0 {
+		return items[0], nil
+	}
+	return models.Season{}, nil
+}
+
+// AdvanceSeasons closes the seasons in progress whose last day is over at
+// now and opens the scheduled ones that started, closing them when they
+// already ended. A scheduled season isn't opened while its group has
+// another one in progress.
+func (h GroupHandler) AdvanceSeasons(ctx context.Context, now time.Time) {
+	today := models.NewDate(now.UTC().Truncate(24 * time.Hour))
+	moves := []struct {
+		status string
+		filter service.Filters
+	}{
+		{SeasonStatusInProgress, service.Filters{"end_date": service.Range{To: today.Add(-time.Millisecond).Format(models.DateLayout)}}},
+		{SeasonStatusScheduled, service.Filters{"start_date": service.Range{To: models.NewDate(now).String()}}},
+	}
+	for _, m := range moves {
+		m.filter["status"] = m.status
+		seasons, err := listAllSeasons(ctx, m.filter)
+		if err != nil {
+			xlog.Error("error while listing seasons", "status", m.status, "error", err)
+			continue
+		}
+		for _, s := range seasons {
+			status := SeasonStatusInProgress
+			if seasonEnded(s, now) {
+				status = SeasonStatusClosed
+			}
+			if status == s.Status {
+				continue
+			}
+			if _, err := seasonSVC.Update(ctx, service.Request{seasonSVC.GetID(): s.ID, "status": status}); err != nil {
+				xlog.Error("error while updating season status", "season", s.ID, "group", s.Group, "status", status, "error", err)
+				continue
+			}
+			xlog.Info("season status updated", "season", s.ID, "group", s.Group, "from", s.Status, "to", status)
+		}
+	}
+}
+
+// listAllSeasons lists the seasons matching filters over every page, before
+// they're updated and move between the pages.
+func listAllSeasons(ctx context.Context, filters service.Filters) ([]models.Season, error) {
+	all := []models.Season{}
+	for page := 1; ; page++ {
+		seasons, err := seasonRepo.List(ctx, service.NewQuery(filters, "start_date").WithPage(page, service.MaxPerPage))
+		if err != nil {
+			return all, err
+		}
+		all = append(all, seasons.V().Items...)
+		if page >= seasons.V().TotalPages {
+			return all, nil
+		}
+	}
+}
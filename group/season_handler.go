@@ -2,10 +2,12 @@ package group
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/a-h/templ"
 	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	"github.com/josuebrunel/sportdropin/pkg/models"
 	"github.com/josuebrunel/sportdropin/pkg/service"
 	"github.com/josuebrunel/sportdropin/pkg/view"
 	"github.com/josuebrunel/sportdropin/pkg/view/component"
@@ -19,21 +21,26 @@ func (h GroupHandler) SeasonCreate(context context.Context) echo.HandlerFunc {
 		if ctx.Request().Method == http.MethodGet {
 			return view.Render(ctx, http.StatusOK,
 				GroupSeasonForm(
-					view.NewViewData(seasonSVC.GetNewRecord(), errorsmap.New()),
+					view.NewViewData(models.Season{Group: groupID, Status: SeasonStatusScheduled}, errorsmap.New()),
 					templ.Attributes{"hx-post": ctx.RouteInfo().Reverse(groupID)}),
 				nil)
 		}
-		req := service.Request{}
-		if err := ctx.Bind(&req); err != nil {
+		var season models.Season
+		if err := ctx.Bind(&season); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		req["group"] = groupID
-		_, err := seasonSVC.Create(context, req)
+		season.Group = groupID
+		vd, err := seasonRepo.Create(actor(ctx, context), season)
 		if err != nil {
-			xlog.Error("error while creating season", "req", req, "error", err)
-			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+			xlog.Error("error while creating season", "season", season, "error", err)
+			view.Retarget(ctx, "closest tr", "outerHTML")
+			return view.Render(ctx, http.StatusOK,
+				GroupSeasonForm(
+					view.NewViewData(season, vd.Errors),
+					templ.Attributes{"hx-post": ctx.RouteInfo().Reverse(groupID)}),
+				nil)
 		}
-		seasons, err := seasonSVC.List(context, map[string]any{"group": groupID})
+		seasons, err := seasonRepo.List(context, service.NewQuery(service.Filters{"group": groupID}, "-start_date"))
 		if err != nil {
 			xlog.Error("error while getting seasons", "group", groupID, "error", err)
 		}
@@ -45,7 +52,8 @@ func (h GroupHandler) SeasonCreate(context context.Context) echo.HandlerFunc {
 func (h GroupHandler) SeasonList(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		groupID := ctx.PathParam(h.svc.GetID())
-		seasons, err := seasonSVC.List(context, map[string]any{"group": groupID})
+		q := service.ParseQuery(ctx.QueryParams(), "-start_date").WithFilters(service.Filters{"group": groupID})
+		seasons, err := seasonRepo.List(context, q)
 		if err != nil {
 			xlog.Error("error while getting seasons", "group", groupID, "error", err)
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
@@ -59,24 +67,34 @@ func (h GroupHandler) SeasonEdit(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		groupID := ctx.PathParam(h.svc.GetID())
 		seasonID := ctx.PathParam(seasonSVC.GetID())
-		vd, _ := seasonSVC.GetByID(context, seasonID)
+		vd, _ := seasonRepo.Get(context, seasonID)
 		if ctx.Request().Method == http.MethodGet {
 			return view.Render(ctx, http.StatusOK,
 				GroupSeasonForm(vd, templ.Attributes{"hx-patch": ctx.RouteInfo().Reverse(groupID, seasonID)}),
 				nil)
 		}
-		req := service.Request{}
-		if err := ctx.Bind(&req); err != nil {
+		season := vd.V()
+		deleted := season.Deleted
+		if err := ctx.Bind(&season); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		req["id"] = seasonID
-		req["group"] = groupID
-		_, err := seasonSVC.Update(context, req)
-		if err != nil {
-			xlog.Error("error while creating season", "req", req, "error", err)
-			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+		season.Deleted = deleted
+		season.ID = seasonID
+		season.Group = groupID
+		if vd, err := seasonRepo.Update(actor(ctx, context), season); err != nil {
+			xlog.Error("error while creating season", "season", season, "error", err)
+			if errors.Is(err, service.ErrConflict) {
+				current, _ := seasonRepo.Get(context, seasonID)
+				season = current.V()
+			}
+			view.Retarget(ctx, "closest tr", "outerHTML")
+			return view.Render(ctx, http.StatusOK,
+				GroupSeasonForm(
+					view.NewViewData(season, vd.Errors),
+					templ.Attributes{"hx-patch": ctx.RouteInfo().Reverse(groupID, seasonID)}),
+				nil)
 		}
-		seasons, err := seasonSVC.List(context, map[string]any{"group": groupID})
+		seasons, err := seasonRepo.List(context, service.NewQuery(service.Filters{"group": groupID}, "-start_date"))
 		if err != nil {
 			xlog.Error("error while getting seasons", "group", groupID, "error", err)
 		}
@@ -85,15 +103,23 @@ func (h GroupHandler) SeasonEdit(context context.Context) echo.HandlerFunc {
 	}
 }
 
+func (h GroupHandler) SeasonDeleteConfirm(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		groupID := ctx.PathParam(h.svc.GetID())
+		seasonID := ctx.PathParam(seasonSVC.GetID())
+		return renderDeleteDialog(ctx, context, seasonSVC, seasonID, "season", reverse(ctx, "season.delete", groupID, seasonID))
+	}
+}
+
 func (h GroupHandler) SeasonDelete(context context.Context) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		groupID := ctx.PathParam(h.svc.GetID())
 		seasonID := ctx.PathParam(seasonSVC.GetID())
-		if err := seasonSVC.Delete(context, seasonID); err != nil {
+		if err := seasonRepo.Delete(actor(ctx, context), seasonID); err != nil {
 			xlog.Error("error while deleting season", "season", seasonID, "error", err)
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		seasons, err := seasonSVC.List(context, map[string]any{"group": groupID})
+		seasons, err := seasonRepo.List(context, service.NewQuery(service.Filters{"group": groupID}, "-start_date"))
 		if err != nil {
 			xlog.Error("error while getting seasons", "group", groupID, "error", err)
 		}
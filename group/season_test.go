@@ -0,0 +1,64 @@
+//go:build !goexperiment.jsonv2
+
+package group
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/pocketbase/dbx"
+)
+
+func TestAdvanceSeasons(t *testing.T) {
+	dao := newTestDao(t)
+	h := NewGroupHandler(dao, "")
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	// more than a page of seasons to close
+	ended := saveRecord(t, dao, "groups", map[string]any{"name": "Lions"})
+	for i := 0; i <= service.MaxPerPage; i++ {
+		saveRecord(t, dao, "seasons", map[string]any{
+			"group": ended.Id, "name": "ended", "status": SeasonStatusInProgress, "start_date": "2023-01-01", "end_date": "2024-05-31",
+		})
+	}
+	running := saveRecord(t, dao, "groups", map[string]any{"name": "Bears"})
+	current := saveRecord(t, dao, "seasons", map[string]any{
+		"group": running.Id, "name": "current", "status": SeasonStatusInProgress, "start_date": "2024-01-01", "end_date": "2024-06-01",
+	})
+	// left scheduled while current is in progress
+	next := saveRecord(t, dao, "seasons", map[string]any{
+		"group": running.Id, "name": "next", "status": SeasonStatusScheduled, "start_date": "2024-05-01", "end_date": "2024-12-31",
+	})
+	started := saveRecord(t, dao, "seasons", map[string]any{
+		"group": ended.Id, "name": "started", "status": SeasonStatusScheduled, "start_date": "2024-06-01", "end_date": "2024-12-31",
+	})
+
+	h.AdvanceSeasons(context.Background(), now)
+
+	n := 0
+	err := dao.RecordQuery("seasons").Select("count(*)").AndWhere(dbx.HashExp{"name": "ended", "status": SeasonStatusInProgress}).Row(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d ended seasons left in progress", n)
+	}
+	tests := []struct {
+		season string
+		want   string
+	}{
+		{current.Id, SeasonStatusInProgress},
+		{next.Id, SeasonStatusScheduled},
+		{started.Id, SeasonStatusInProgress},
+	}
+	for _, tt := range tests {
+		s, err := dao.FindRecordById("seasons", tt.season)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.GetString("status"); got != tt.want {
+			t.Errorf("%s: got %s, want %s", s.GetString("name"), got, tt.want)
+		}
+	}
+}
@@ -3,12 +3,17 @@ package group
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/a-h/templ"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/josuebrunel/sportdropin/pkg/collection"
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
 	"github.com/josuebrunel/sportdropin/pkg/models"
 	"github.com/josuebrunel/sportdropin/pkg/service"
 	"github.com/josuebrunel/sportdropin/pkg/util"
@@ -18,47 +23,113 @@ import (
 	"github.com/labstack/echo/v5"
 )
 
-func memberStatsToData(sport models.Sport, rr service.RecordSlice) []map[string]string {
-	data := collection.Transform(rr, func(r service.Record) map[string]string {
-		d := map[string]string{}
-		d["id"] = r.GetId()
-		d["username"] = r.GetString("username")
-		stat := r.ExpandedOne("memberstats")
-		xlog.Debug("member stats", "stats", stat)
-		if stat != nil {
-			var stats = make(map[string]string)
-			d["stats_id"] = stat.GetId()
-			stat.UnmarshalJSONField("stats", &stats)
-			for _, k := range sport.Data.Stats {
-				d[k.Abbr] = stats[k.Abbr]
-			}
+// ErrNoSeason is returned for a stat sheet submitted without its season.
+var ErrNoSeason = errors.New("the season of the stats is missing")
+
+// statRow is a member and its stats for a season, in the stat sheet.
+type statRow struct {
+	ID           string
+	Username     string
+	StatsID      string
+	StatsUpdated string
+	Stats        models.StatValues
+	// Input holds the submitted values of a rejected sheet, by abbr.
+	Input map[string]string
+}
+
+// Value returns the input value of the stat s.
+func (r statRow) Value(s models.SportStat) string {
+	if v, ok := r.Input[s.Abbr]; ok {
+		return v
+	}
+	return r.Stats[s.Abbr].Input()
+}
+
+// Checked reports whether the boolean stat s is set.
+func (r statRow) Checked(s models.SportStat) bool {
+	v, _ := s.Parse(r.Value(s))
+	return v.Bool()
+}
+
+func memberStatsToRows(sport models.Sport, mm []models.Member) []statRow {
+	rows := collection.Transform(mm, func(m models.Member) statRow {
+		row := statRow{ID: m.ID, Username: m.Username, Input: map[string]string{}}
+		var stats models.StatValues
+		if len(m.Expand.Stats) > 0 {
+			stat := m.Expand.Stats[0]
+			row.StatsID, row.StatsUpdated, stats = stat.ID, stat.Updated, stat.Stats
 		}
-		return d
+		row.Stats = stats.Typed(sport.Data.Stats)
+		return row
+	})
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Stats.Float(sport.Data.Top.Abbr) > rows[j].Stats.Float(sport.Data.Top.Abbr)
 	})
-	return data
+	return rows
+}
+
+// listMemberStats lists the roster of a group with the stats of its members
+// for season.
+func listMemberStats(context context.Context, groupID, seasonID string) ([]models.Member, error) {
+	members, err := memberSVC.ListWithBackRel(
+		context, rosterQuery(groupID),
+		service.BackRel{
+			Collection: "memberstats",
+			Key:        "member",
+			Filters:    service.Filters{"group": groupID, "season": seasonID},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return service.ToModels[models.Member](members.V().Items)
+}
+
+// formValue returns the bound form value v as typed, the binder turning
+// the numbers and booleans of the form into float64 and bool.
+func formValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	}
+	return fmt.Sprint(v)
 }
 
-func formDataToRequests(groupID string, formData map[string]any, sport models.Sport) service.Requests {
+// formDataToRequests turns a stat sheet into the stat upsert requests of
+// its members. The values failing to parse are reported under their field
+// name.
+func formDataToRequests(groupID string, formData map[string]any, sport models.Sport) (service.Requests, errorsmap.EMap) {
 	requests := map[string]service.Request{}
-	stats := map[string]map[string]string{}
+	stats := map[string]models.StatValues{}
+	em := errorsmap.New()
 	for field, value := range formData {
 		sf := strings.Split(field, ":")
 		if len(sf) > 1 {
 			id, fname := sf[0], sf[1]
 			// process stat fields
-			if collection.Exists(sport.Data.Stats, func(s models.SportStat) bool { return strings.EqualFold(s.Abbr, fname) }) {
-				if v, ok := stats[id]; ok {
-					v[fname] = util.F64Fmt(util.AssertType[float64](value), "%.f")
-				} else {
-					stats[id] = map[string]string{fname: util.F64Fmt(util.AssertType[float64](value), "%.f")}
+			if stat := collection.Get(sport.Data.Stats, func(s models.SportStat) bool { return strings.EqualFold(s.Abbr, fname) }); stat != nil {
+				v, err := stat.Parse(formValue(value))
+				if err != nil {
+					em[field] = err
+					continue
+				}
+				if _, ok := stats[id]; !ok {
+					stats[id] = models.StatValues{}
 				}
+				stats[id][stat.Abbr] = v
 				// process request fields
 			} else {
 				if m, ok := requests[id]; ok {
-					m[fname] = value
+					m[fname] = formValue(value)
 					requests[id] = m
 				} else {
-					requests[id] = service.Request{fname: value}
+					requests[id] = service.Request{fname: formValue(value)}
 				}
 			}
 		}
@@ -69,7 +140,7 @@ func formDataToRequests(groupID string, formData map[string]any, sport models.Sp
 		v["group"] = groupID
 		v["season"] = formData["season"]
 		if s, ok := stats[i]; ok {
-			js, err := json.Marshal(&s)
+			js, err := json.Marshal(s)
 			if err != nil {
 				xlog.Error("error while marshalling stats", "member", i, "stats", stats[i])
 			}
@@ -77,8 +148,137 @@ func formDataToRequests(groupID string, formData map[string]any, sport models.Sp
 		}
 		r = append(r, v)
 	}
+	if len(em) > 0 {
+		em["error"] = service.ErrValidation
+	}
 	xlog.Debug("requests-data", "requests", r)
-	return r
+	return r, em
+}
+
+// rosterQuery returns the query listing the whole roster of a group, the
+// stats ranking being computed over all its members.
+func rosterQuery(groupID string) service.Query {
+	return service.NewQuery(service.Filters{"group": groupID}, "username").WithPage(1, service.MaxPerPage)
+}
+
+// memberErrors re-keys the per index errors of a stat requests bulk
+// operation by member id.
+func memberErrors(reqs service.Requests, em errorsmap.EMap) errorsmap.EMap {
+	errs := errorsmap.EMap{"error": em["error"]}
+	for i, r := range reqs {
+		err := em[strconv.Itoa(i)]
+		if err == nil {
+			continue
+		}
+		member := util.AssertType[string](r["member"])
+		var verrs validation.Errors
+		if !errors.As(err, &verrs) {
+			errs[member] = err
+			continue
+		}
+		for k, v := range verrs {
+			if abbr, ok := strings.CutPrefix(k, "stats."); ok {
+				errs[genFieldName(member, abbr)] = v
+			} else {
+				errs[member] = v
+			}
+		}
+	}
+	return errs
+}
+
+// withoutConflicts drops from the submitted stat sheet the values of the
+// members whose stats changed meanwhile, so their current stats are shown.
+func withoutConflicts(submitted service.Request, em errorsmap.EMap) service.Request {
+	req := service.Request{}
+	for k, v := range submitted {
+		member, _, _ := strings.Cut(k, ":")
+		if !errors.Is(em[member], service.ErrConflict) {
+			req[k] = v
+		}
+	}
+	return req
+}
+
+// listInGroup lists the records of svc among ids belonging to the group
+// groupID.
+func listInGroup(context context.Context, svc service.Service, groupID string, ids []any) (service.RecordSlice, error) {
+	vd, err := svc.List(context, service.NewQuery(service.Filters{"id": service.In(ids), "group": groupID}).WithPage(1, service.MaxPerPage))
+	if err != nil {
+		return nil, err
+	}
+	return vd.V().Items, nil
+}
+
+// checkStatRequests makes sure the season, the members and the stats of a
+// stat sheet, their ids coming from the form, belong to the group groupID.
+// It returns ErrNotFound otherwise.
+func checkStatRequests(context context.Context, groupID, seasonID string, reqs service.Requests) error {
+	seasons, err := listInGroup(context, seasonSVC, groupID, []any{seasonID})
+	if err != nil || len(seasons) != 1 {
+		return ErrNotFound
+	}
+	members, stats := map[string]bool{}, map[string]string{}
+	for _, r := range reqs {
+		member := util.AssertType[string](r["member"])
+		members[member] = true
+		if id := util.AssertType[string](r["id"]); id != "" {
+			stats[id] = member
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+	memberIDs := make([]any, 0, len(members))
+	for id := range members {
+		memberIDs = append(memberIDs, id)
+	}
+	found, err := listInGroup(context, memberSVC, groupID, memberIDs)
+	if err != nil || len(found) != len(members) {
+		return ErrNotFound
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	statIDs := make([]any, 0, len(stats))
+	for id := range stats {
+		statIDs = append(statIDs, id)
+	}
+	found, err = listInGroup(context, statSVC, groupID, statIDs)
+	if err != nil || len(found) != len(stats) {
+		return ErrNotFound
+	}
+	for _, s := range found {
+		if s.GetString("member") != stats[s.Id] || s.GetString("season") != seasonID {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// renderStatForm renders the stat sheet of a season. submitted, if any,
+// overrides the stored values so a rejected sheet is not lost.
+func (h GroupHandler) renderStatForm(ctx echo.Context, context context.Context, group models.Group, sport models.Sport, seasonID string, submitted service.Request, em errorsmap.EMap) error {
+	members, err := listMemberStats(context, group.ID, seasonID)
+	if err != nil {
+		xlog.Error("error while getting members and stats", "group", group.ID, "error", err)
+		return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+	}
+	group.Extra = models.Extra{"curseason": seasonID}
+
+	rows := memberStatsToRows(sport, members)
+	for _, r := range rows {
+		for _, f := range sport.Data.Stats {
+			if v, ok := submitted[genFieldName(r.ID, f.Abbr)]; ok {
+				r.Input[f.Abbr] = formValue(v)
+			}
+		}
+	}
+	return view.Render(ctx, http.StatusOK,
+		GroupStatForm(
+			group, sport, rows, em,
+			templ.Attributes{"hx-post": view.ReverseX(ctx, "stat.create", group.ID), "hx-target": "#content"}),
+		nil)
 }
 
 func (h GroupHandler) StatCreate(context context.Context) echo.HandlerFunc {
@@ -93,67 +293,42 @@ func (h GroupHandler) StatCreate(context context.Context) echo.HandlerFunc {
 				xlog.Error("error while getting current season", "group", groupID, "error", err)
 				return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 			}
-			seasonID = curSeason.GetId()
+			seasonID = curSeason.ID
 		}
-		var err error
 		if ctx.Request().Method == http.MethodGet {
-			members, err := memberSVC.ListWithBackRel(
-				context, service.Filters{"group": groupID},
-				service.BackRel{
-					"memberstats": map[string]any{"member": ":id", "group": groupID, "season": seasonID},
-				},
-			)
-			if err != nil {
-				xlog.Error("error while getting members and stats", "group", groupID, "error", err)
-				return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
-			}
-			var m models.Group
-			_ = service.UnmarshalTo(group, &m)
-			m.Extra = models.Extra{"curseason": seasonID}
-
-			data := memberStatsToData(sport, members.V())
-			sort.Slice(data, func(i, j int) bool {
-				return util.F64(data[i][sport.Data.Top.Abbr]) > util.F64(data[j][sport.Data.Top.Abbr])
-			})
-			xlog.Debug("members stats", "stats", data)
-			return view.Render(ctx, http.StatusOK,
-				GroupStatForm(
-					m, sport, data,
-					templ.Attributes{"hx-post": ctx.RouteInfo().Reverse(groupID), "hx-target": "#content"}),
-				nil)
+			return h.renderStatForm(ctx, context, group, sport, seasonID, nil, errorsmap.New())
 		}
 		req := service.Request{}
 		if err := ctx.Bind(&req); err != nil {
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
 
-		seasonID = req["season"].(string)
-		reqs := formDataToRequests(groupID, req, sport)
+		if seasonID = util.AssertType[string](req["season"]); seasonID == "" {
+			return view.Render(ctx, http.StatusOK, component.Error(ErrNoSeason.Error()), nil)
+		}
+		reqs, em := formDataToRequests(groupID, req, sport)
+		if err := checkStatRequests(context, groupID, seasonID, reqs); err != nil {
+			xlog.Warn("stat sheet outside of its group", "group", groupID, "season", seasonID, "error", err)
+			return view.Render(ctx, http.StatusNotFound, component.Error(ErrNotFound.Error()), nil)
+		}
+		if !em.Nil() {
+			return h.renderStatForm(ctx, context, group, sport, seasonID, req, em)
+		}
 		xlog.Debug("request data", "requests", reqs)
-		_, err = statSVC.BulkUpsert(context, reqs)
+		vd, err := statSVC.BulkUpsert(actor(ctx, context), reqs, service.BulkAtomic)
 		if err != nil {
 			xlog.Error("error while creating stat", "reqs", reqs, "error", err)
-			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+			em := memberErrors(reqs, vd.Errors)
+			return h.renderStatForm(ctx, context, group, sport, seasonID, withoutConflicts(req, em), em)
 		}
-		members, err := memberSVC.ListWithBackRel(
-			context, service.Filters{"group": groupID},
-			service.BackRel{
-				"memberstats": map[string]any{"member": ":id", "group": groupID, "season": seasonID},
-			},
-		)
+		members, err := listMemberStats(context, groupID, seasonID)
 		if err != nil {
 			xlog.Error("error while getting members and stats", "group", groupID, "error", err)
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
-		data := memberStatsToData(sport, members.V())
-		sort.Slice(data, func(i, j int) bool {
-			return util.F64(data[i][sport.Data.Top.Abbr]) > util.F64(data[j][sport.Data.Top.Abbr])
-		})
-		xlog.Debug("members stats", "stats", data)
-		var m models.Group
-		_ = service.UnmarshalTo(group, &m)
-		m.Extra = models.Extra{"curseason": seasonID}
-		return view.Render(ctx, http.StatusOK, GroupStatList(m, data, sport), nil)
+		rows := memberStatsToRows(sport, members)
+		group.Extra = models.Extra{"curseason": seasonID}
+		return view.Render(ctx, http.StatusOK, GroupStatList(group, rows, sport), nil)
 	}
 }
 
@@ -168,29 +343,17 @@ func (h GroupHandler) StatList(context context.Context) echo.HandlerFunc {
 				xlog.Error("error while getting current season", "group", groupID, "error", err)
 				return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 			}
-			seasonID = curSeason.GetId()
+			seasonID = curSeason.ID
 		}
 
-		members, err := memberSVC.ListWithBackRel(
-			context, service.Filters{"group": groupID},
-			service.BackRel{
-				"memberstats": map[string]any{"member": ":id", "group": groupID, "season": seasonID},
-			},
-		)
+		members, err := listMemberStats(context, groupID, seasonID)
 		if err != nil {
 			xlog.Error("error while getting members and stats", "group", groupID, "error", err)
 			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
 		}
 		sport := h.GetGroupSport(context, groupID)
-		xlog.Debug("members and stats", "members", members, "sport", sport, "group", group)
-		data := memberStatsToData(sport, members.V())
-		sort.Slice(data, func(i, j int) bool {
-			return util.F64(data[i][sport.Data.Top.Abbr]) > util.F64(data[j][sport.Data.Top.Abbr])
-		})
-		xlog.Debug("stats", "stats", data)
-		var m models.Group
-		_ = service.UnmarshalTo(group, &m)
-		m.Extra = models.Extra{"curseason": seasonID}
-		return view.Render(ctx, http.StatusOK, GroupStatList(m, data, sport), nil)
+		rows := memberStatsToRows(sport, members)
+		group.Extra = models.Extra{"curseason": seasonID}
+		return view.Render(ctx, http.StatusOK, GroupStatList(group, rows, sport), nil)
 	}
 }
@@ -0,0 +1,131 @@
+package group
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/josuebrunel/sportdropin/pkg/service"
+	"github.com/josuebrunel/sportdropin/pkg/view"
+	"github.com/josuebrunel/sportdropin/pkg/view/component"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/josuebrunel/sportdropin/pkg/xsession"
+	"github.com/labstack/echo/v5"
+)
+
+// trashServices returns the services whose records go to the trash, by
+// kind.
+func (h GroupHandler) trashServices() map[string]service.Service {
+	return map[string]service.Service{"member": memberSVC, "season": seasonSVC, "group": h.svc}
+}
+
+// ownedGroupIDs returns the ids of the groups of userID, trashed or not.
+func (h GroupHandler) ownedGroupIDs(ctx context.Context, userID string) ([]any, error) {
+	q := service.NewQuery(service.Filters{"user": userID}).WithPage(1, service.MaxPerPage)
+	ids := []any{}
+	for _, q := range []service.Query{q, q.WithTrash()} {
+		groups, err := h.repo.List(ctx, q)
+		if err != nil {
+			return ids, err
+		}
+		for _, g := range groups.V().Items {
+			ids = append(ids, g.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (h GroupHandler) renderTrash(ctx echo.Context, context context.Context) error {
+	user := xsession.GetUser(ctx.Request().Context())
+	ids, err := h.ownedGroupIDs(context, user.ID)
+	if err != nil {
+		xlog.Error("error while getting user groups", "user", user.ID, "error", err)
+		return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+	}
+	q := service.NewQuery(service.Filters{}, "-"+service.DeletedField).WithPage(1, service.MaxPerPage).WithTrash()
+	groups, err := h.repo.List(context, q.WithFilters(service.Filters{"user": user.ID}))
+	if err != nil {
+		return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+	}
+	seasons, err := seasonRepo.List(context, q.WithFilters(service.Filters{"group": service.In(ids)}))
+	if err != nil {
+		return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+	}
+	members, err := memberRepo.List(context, q.WithFilters(service.Filters{"group": service.In(ids)}))
+	if err != nil {
+		return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+	}
+	return view.Render(ctx, http.StatusOK, GroupTrashView(groups.V().Items, seasons.V().Items, members.V().Items), nil)
+}
+
+func (h GroupHandler) Trash(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		return h.renderTrash(ctx, context)
+	}
+}
+
+// trashed returns the service of the trashed record of the route, making
+// sure it belongs to a group of the session user.
+func (h GroupHandler) trashed(ctx echo.Context, context context.Context) (service.Service, string, error) {
+	kind, id := ctx.PathParam("kind"), ctx.PathParam("trashid")
+	svc, ok := h.trashServices()[kind]
+	if !ok {
+		return svc, id, ErrNotFound
+	}
+	vd, err := svc.List(context, service.NewQuery(service.Filters{"id": id}).WithTrash())
+	if err != nil || len(vd.V().Items) == 0 {
+		return svc, id, ErrNotFound
+	}
+	groupID := vd.V().Items[0].GetString("group")
+	if kind == "group" {
+		groupID = id
+	}
+	ids, err := h.ownedGroupIDs(context, xsession.GetUser(ctx.Request().Context()).ID)
+	if err != nil || !slices.Contains(ids, any(groupID)) {
+		return svc, id, ErrNotFound
+	}
+	return svc, id, nil
+}
+
+func (h GroupHandler) TrashRestore(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		svc, id, err := h.trashed(ctx, context)
+		if err == nil {
+			err = svc.Restore(actor(ctx, context), id)
+		}
+		if err != nil {
+			xlog.Error("error while restoring", "collection", svc.Name, "record", id, "error", err)
+			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+		}
+		return h.renderTrash(ctx, context)
+	}
+}
+
+func (h GroupHandler) TrashPurge(context context.Context) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		svc, id, err := h.trashed(ctx, context)
+		if err == nil {
+			err = svc.Purge(actor(ctx, context), id)
+		}
+		if err != nil {
+			xlog.Error("error while purging", "collection", svc.Name, "record", id, "error", err)
+			return view.Render(ctx, http.StatusOK, component.Error(err.Error()), nil)
+		}
+		return h.renderTrash(ctx, context)
+	}
+}
+
+// PurgeTrash removes the groups, seasons and members trashed for longer
+// than retention.
+func (h GroupHandler) PurgeTrash(ctx context.Context, retention time.Duration) {
+	before := time.Now().Add(-retention)
+	for _, kind := range []string{"member", "season", "group"} {
+		svc := h.trashServices()[kind]
+		n, err := svc.PurgeTrash(ctx, before)
+		if err != nil {
+			xlog.Error("error while purging the trash", "collection", svc.Name, "error", err)
+		}
+		xlog.Info("trash purged", "collection", svc.Name, "records", n)
+	}
+}
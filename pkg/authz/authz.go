@@ -0,0 +1,106 @@
+package authz
+
+import (
+	"errors"
+	"slices"
+)
+
+var ErrForbidden = errors.New("you are not allowed to do this")
+
+type Action string
+
+const (
+	View   Action = "view"
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Role is the role of a user on a group. Roles are ordered, a role is
+// granted everything the lower ones are.
+type Role int
+
+const (
+	Anonymous Role = iota
+	// User is any logged in user.
+	User
+	// Manager is a user listed in the managers of the group.
+	Manager
+	// Owner is the user who created the group.
+	Owner
+)
+
+// Kinds of resource.
+const (
+	Group  = "group"
+	Season = "season"
+	Member = "member"
+	Stat   = "stat"
+	// History is the change history of a group resources.
+	History = "history"
+	// Trash is the trash of a user, the handlers check the ownership of
+	// the trashed resources.
+	Trash = "trash"
+)
+
+// Resource is a group or a resource of a group.
+type Resource struct {
+	Kind     string
+	Owner    string
+	Managers []string
+}
+
+// Policy holds the lowest role allowed to run an action on a kind of
+// resource. Actions left out are denied.
+var Policy = map[string]map[Action]Role{
+	Group: {
+		View:   Anonymous,
+		Create: User,
+		Update: Manager,
+		Delete: Owner,
+	},
+	Season: {
+		View:   Manager,
+		Create: Manager,
+		Update: Manager,
+		Delete: Manager,
+	},
+	Member: {
+		View:   Manager,
+		Create: Manager,
+		Update: Manager,
+		Delete: Manager,
+	},
+	Stat: {
+		View:   Anonymous,
+		Create: Manager,
+	},
+	History: {
+		View:   Manager,
+		Update: Manager,
+	},
+	Trash: {
+		View:   User,
+		Update: User,
+		Delete: User,
+	},
+}
+
+// RoleOf returns the role of the user userID, empty when anonymous, on r.
+func RoleOf(userID string, r Resource) Role {
+	switch {
+	case userID == "":
+		return Anonymous
+	case userID == r.Owner:
+		return Owner
+	case slices.Contains(r.Managers, userID):
+		return Manager
+	}
+	return User
+}
+
+// Can reports whether the user userID may run action on r.
+func Can(userID string, action Action, r Resource) bool {
+	role, ok := Policy[r.Kind][action]
+	return ok && RoleOf(userID, r) >= role
+}
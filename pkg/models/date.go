@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DateLayout is the layout of the PocketBase date fields.
+const DateLayout = "2006-01-02 15:04:05.000Z"
+
+var dateLayouts = []string{DateLayout, time.RFC3339Nano, "2006-01-02 15:04:05Z07:00", time.DateTime, time.DateOnly}
+
+// Date is the value of a PocketBase date field, in UTC. The zero Date is
+// an empty field.
+type Date struct {
+	time.Time
+}
+
+// NewDate returns t as a Date.
+func NewDate(t time.Time) Date {
+	if t.IsZero() {
+		return Date{}
+	}
+	return Date{t.UTC()}
+}
+
+// ParseDate parses s in one of the PocketBase layouts or as a date input
+// value. An empty s is the zero Date.
+func ParseDate(s string) (Date, error) {
+	if s == "" {
+		return Date{}, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return NewDate(t), nil
+		}
+	}
+	return Date{}, fmt.Errorf("invalid date %q", s)
+}
+
+// String formats d with DateLayout, or returns "" when zero.
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.UTC().Format(DateLayout)
+}
+
+// DateOnly formats d as a date input value.
+func (d Date) DateOnly() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.UTC().Format(time.DateOnly)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.UnmarshalParam(s)
+}
+
+// UnmarshalParam binds a form value.
+func (d *Date) UnmarshalParam(s string) error {
+	date, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = date
+	return nil
+}
@@ -76,19 +76,22 @@ type Sport struct {
 }
 
 type Group struct {
-	Extra          `json:"extra"`
-	ID             string `json:"id,omitempty" form:"id"`
-	User           string `json:"user" form:"user"`
-	City           string `json:"city" form:"city"`
-	CollectionID   string `json:"collectionId"`
-	CollectionName string `json:"collectionName"`
-	Country        string `json:"country" form:"country"`
-	Created        string `json:"created" form:"created"`
-	Description    string `json:"description" form:"description"`
-	Name           string `json:"name" form:"name"`
-	Sport          string `json:"sport" form:"sport"`
-	Street         string `json:"street" form:"street"`
-	Updated        string `json:"updated" form:"updated"`
+	Extra `json:"extra"`
+	ID    string `json:"id,omitempty" form:"id"`
+	User  string `json:"user" form:"user"`
+	// Managers are the users allowed to manage the group besides its owner.
+	Managers       []string `json:"managers"`
+	City           string   `json:"city" form:"city"`
+	CollectionID   string   `json:"collectionId"`
+	CollectionName string   `json:"collectionName"`
+	Country        string   `json:"country" form:"country"`
+	Created        string   `json:"created" form:"created"`
+	Description    string   `json:"description" form:"description"`
+	Name           string   `json:"name" form:"name"`
+	Sport          string   `json:"sport" form:"sport"`
+	Street         string   `json:"street" form:"street"`
+	Updated        string   `json:"updated" form:"updated"`
+	Deleted        string   `json:"deleted"`
 	Expand         struct {
 		User    User     `json:"user" form:"user"`
 		Members []Member `json:"members_via_group" form:"members_via_group"`
@@ -103,10 +106,11 @@ type Season struct {
 	CollectionName string `json:"collectionName"`
 	Created        string `json:"created" form:"created"`
 	Updated        string `json:"updated" form:"updated"`
+	Deleted        string `json:"deleted"`
 	Name           string `json:"name" form:"name"`
 	Status         string `json:"status" form:"status"`
-	StartDate      string `json:"start_date" form:"start_date"`
-	EndDate        string `json:"end_date" form:"end_date"`
+	StartDate      Date   `json:"start_date" form:"start_date"`
+	EndDate        Date   `json:"end_date" form:"end_date"`
 	Group          string `json:"group" form:"group"`
 	Expand         struct {
 		Group Group `json:"group" form:"group"`
@@ -119,28 +123,52 @@ type Member struct {
 	CollectionName string `json:"collectionName"`
 	Created        string `json:"created" form:"created"`
 	Updated        string `json:"updated" form:"updated"`
+	Deleted        string `json:"deleted"`
 	Group          string `json:"group" form:"group"`
 	Username       string `json:"username" form:"username"`
 	Email          string `json:"email" form:"email"`
 	Phone          string `json:"phone" form:"phone"`
 	Expand         struct {
-		Group Group `json:"group" form:"group"`
+		Group Group        `json:"group" form:"group"`
+		Stats []MemberStat `json:"memberstats" form:"memberstats"`
 	} `json:"expand,omitempty" form:"expand"`
 }
 
 type MemberStat struct {
-	ID             string    `json:"id,omitempty" form:"id"`
-	CollectionID   string    `json:"collectionId"`
-	CollectionName string    `json:"collectionName"`
-	Created        string    `json:"created" form:"created"`
-	Updated        string    `json:"updated" form:"updated"`
-	Group          string    `json:"group" form:"group"`
-	Member         string    `json:"member" form:"member"`
-	Season         string    `json:"season" form:"season"`
-	Stats          SportStat `json:"stats" form:"stats"`
+	ID             string     `json:"id,omitempty" form:"id"`
+	CollectionID   string     `json:"collectionId"`
+	CollectionName string     `json:"collectionName"`
+	Created        string     `json:"created" form:"created"`
+	Updated        string     `json:"updated" form:"updated"`
+	Group          string     `json:"group" form:"group"`
+	Member         string     `json:"member" form:"member"`
+	Season         string     `json:"season" form:"season"`
+	Stats          StatValues `json:"stats" form:"stats"`
 	Expand         struct {
 		Group  Group  `json:"group" form:"group"`
 		Member Member `json:"member" form:"member"`
 		Season Season `json:"season" form:"season"`
 	} `json:"expand,omitempty" form:"expand"`
 }
+
+type HistoryChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+type RecordHistory struct {
+	ID             string                   `json:"id,omitempty"`
+	CollectionID   string                   `json:"collectionId"`
+	CollectionName string                   `json:"collectionName"`
+	Created        string                   `json:"created"`
+	Updated        string                   `json:"updated"`
+	Collection     string                   `json:"collection"`
+	Record         string                   `json:"record"`
+	Scope          string                   `json:"scope"`
+	Op             string                   `json:"op"`
+	Actor          string                   `json:"actor"`
+	Diff           map[string]HistoryChange `json:"diff"`
+	Expand         struct {
+		Actor User `json:"actor"`
+	} `json:"expand,omitempty"`
+}
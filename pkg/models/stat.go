@@ -0,0 +1,310 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatType is the type of the values of a SportStat.
+type StatType string
+
+const (
+	StatNumber     StatType = "number"
+	StatDuration   StatType = "duration"
+	StatPercentage StatType = "percentage"
+	StatBoolean    StatType = "boolean"
+)
+
+var (
+	ErrStatNumber     = errors.New("must be a number")
+	ErrStatDuration   = errors.New("must be a duration, i.e. 1:05:30")
+	ErrStatPercentage = errors.New("must be between 0 and 100")
+	ErrStatBoolean    = errors.New("must be yes or no")
+)
+
+// Kind returns the StatType of s, StatNumber when its Type is unknown.
+func (s SportStat) Kind() StatType {
+	switch t := StatType(strings.ToLower(s.Type)); t {
+	case StatDuration, StatPercentage, StatBoolean:
+		return t
+	}
+	return StatNumber
+}
+
+// InputType returns the type of the form input of s.
+func (s SportStat) InputType() string {
+	switch s.Kind() {
+	case StatBoolean:
+		return "checkbox"
+	case StatDuration:
+		return "text"
+	}
+	return "number"
+}
+
+// step returns the Step of s as a number, 0 when there is none.
+func (s SportStat) step() float64 {
+	step, err := strconv.ParseFloat(s.Step, 64)
+	if err != nil || step <= 0 {
+		return 0
+	}
+	return step
+}
+
+// round rounds v to the Step of s, keeping as many decimals as Step.
+func (s SportStat) round(v float64) float64 {
+	step := s.step()
+	if step == 0 {
+		return v
+	}
+	// v/step is first rounded to 9 decimals, i.e. 45.55/0.1 is 455.49999...
+	q := math.Round(v/step*1e9) / 1e9
+	v = math.Round(q) * step
+	decimals := 0
+	if _, frac, ok := strings.Cut(s.Step, "."); ok {
+		decimals = len(frac)
+	}
+	p := math.Pow10(decimals)
+	return math.Round(v*p) / p
+}
+
+// Parse parses the form value of s: a number, a percentage, a duration as
+// [[h:]m:]s or a checkbox value. Numbers are rounded to the Step of s.
+func (s SportStat) Parse(value string) (StatValue, error) {
+	value = strings.TrimSpace(value)
+	v := StatValue{Type: s.Kind()}
+	switch v.Type {
+	case StatBoolean:
+		switch strings.ToLower(value) {
+		case "", "0", "false", "off", "no":
+		case "1", "true", "on", "yes":
+			v.Value = 1
+		default:
+			return v, ErrStatBoolean
+		}
+		return v, nil
+	case StatDuration:
+		d, err := parseDuration(value)
+		if err != nil {
+			return v, ErrStatDuration
+		}
+		v.Value = d
+	default:
+		if value == "" {
+			return v, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return v, ErrStatNumber
+		}
+		if v.Type == StatPercentage && (f < 0 || f > 100) {
+			return v, ErrStatPercentage
+		}
+		v.Value = f
+	}
+	v.Value = s.round(v.Value)
+	return v, nil
+}
+
+// parseDuration parses [[h:]m:]s, the seconds possibly with decimals, or a
+// Go duration such as 1h5m30s, into seconds.
+func parseDuration(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d.Seconds(), nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, ErrStatDuration
+	}
+	secs := 0.0
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(p, 64)
+		if err != nil || f < 0 || (i > 0 && f >= 60) || (i < len(parts)-1 && f != math.Trunc(f)) {
+			return 0, ErrStatDuration
+		}
+		secs = secs*60 + f
+	}
+	return secs, nil
+}
+
+// StatValue is the value of a stat: durations are in seconds, percentages
+// from 0 to 100 and booleans 0 or 1. Booleans are stored as json booleans,
+// the others as json numbers.
+type StatValue struct {
+	Type  StatType
+	Value float64
+}
+
+// Bool reports whether a boolean v is set.
+func (v StatValue) Bool() bool {
+	return v.Value != 0
+}
+
+// Input formats v as a form input value.
+func (v StatValue) Input() string {
+	switch v.Type {
+	case StatDuration:
+		return formatDuration(v.Value)
+	case StatBoolean:
+		if v.Bool() {
+			return "on"
+		}
+		return ""
+	}
+	return strconv.FormatFloat(v.Value, 'f', -1, 64)
+}
+
+// String formats v for display.
+func (v StatValue) String() string {
+	switch v.Type {
+	case StatPercentage:
+		return v.Input() + "%"
+	case StatBoolean:
+		if v.Bool() {
+			return "yes"
+		}
+		return "no"
+	}
+	return v.Input()
+}
+
+// formatDuration formats secs as [h:]m:ss, to the millisecond.
+func formatDuration(secs float64) string {
+	ms := int64(math.Round(secs * 1000))
+	whole, frac := ms/1000, ""
+	if ms%1000 != 0 {
+		frac = strings.TrimRight(fmt.Sprintf(".%03d", ms%1000), "0")
+	}
+	h, m, s := whole/3600, whole/60%60, whole%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d%s", h, m, s, frac)
+	}
+	return fmt.Sprintf("%d:%02d%s", m, s, frac)
+}
+
+// Add returns the sum of v and o. Summing booleans counts the true ones.
+func (v StatValue) Add(o StatValue) StatValue {
+	t := v.Type
+	if t == "" {
+		t = o.Type
+	}
+	if t == StatBoolean {
+		t = StatNumber
+	}
+	return StatValue{Type: t, Value: v.Value + o.Value}
+}
+
+// Div returns v divided by n, i.e. to average a sum. It returns v when n
+// is 0.
+func (v StatValue) Div(n float64) StatValue {
+	if n == 0 {
+		return v
+	}
+	if v.Type == StatBoolean {
+		v.Type = StatNumber
+	}
+	v.Value /= n
+	return v
+}
+
+func (v StatValue) MarshalJSON() ([]byte, error) {
+	if v.Type == StatBoolean {
+		return json.Marshal(v.Bool())
+	}
+	return json.Marshal(v.Value)
+}
+
+// UnmarshalJSON reads a json number, boolean or, as stored by the former
+// stat sheet, a string.
+func (v *StatValue) UnmarshalJSON(b []byte) error {
+	var raw any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	switch raw := raw.(type) {
+	case nil:
+		*v = StatValue{}
+	case bool:
+		*v = StatValue{Type: StatBoolean}
+		if raw {
+			v.Value = 1
+		}
+	case float64:
+		*v = StatValue{Type: StatNumber, Value: raw}
+	case string:
+		stat := SportStat{}
+		if strings.Contains(raw, ":") {
+			stat.Type = string(StatDuration)
+		}
+		parsed, err := stat.Parse(raw)
+		if err != nil {
+			return err
+		}
+		*v = parsed
+	default:
+		return fmt.Errorf("invalid stat value %s", b)
+	}
+	return nil
+}
+
+// StatValues are the stats of a member, by SportStat abbr.
+type StatValues map[string]StatValue
+
+// Typed returns a copy of vv holding a value of the type of each of
+// stats, the missing ones being zero.
+func (vv StatValues) Typed(stats []SportStat) StatValues {
+	typed := StatValues{}
+	for _, s := range stats {
+		v := vv[s.Abbr]
+		v.Type = s.Kind()
+		if v.Type == StatBoolean && v.Value != 0 {
+			v.Value = 1
+		}
+		typed[s.Abbr] = v
+	}
+	return typed
+}
+
+// Float returns the value of the stat abbr, 0 when missing.
+func (vv StatValues) Float(abbr string) float64 {
+	return vv[abbr].Value
+}
+
+// Add returns the sum of vv and o, by abbr.
+func (vv StatValues) Add(o StatValues) StatValues {
+	sum := StatValues{}
+	for k, v := range vv {
+		sum[k] = v
+	}
+	for k, v := range o {
+		sum[k] = sum[k].Add(v)
+	}
+	return sum
+}
+
+// Div returns vv divided by n, i.e. to average a sum.
+func (vv StatValues) Div(n float64) StatValues {
+	res := StatValues{}
+	for k, v := range vv {
+		res[k] = v.Div(n)
+	}
+	return res
+}
+
+// SumStats returns the sum of vv, i.e. the totals of a member over
+// several seasons.
+func SumStats(vv ...StatValues) StatValues {
+	sum := StatValues{}
+	for _, v := range vv {
+		sum = sum.Add(v)
+	}
+	return sum
+}
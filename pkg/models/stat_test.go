@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSportStatParse(t *testing.T) {
+	goals := SportStat{Abbr: "g", Type: "number", Step: "1"}
+	distance := SportStat{Abbr: "km", Type: "number", Step: "0.1"}
+	tests := []struct {
+		stat  SportStat
+		value string
+		want  StatValue
+		err   error
+	}{
+		{goals, "3", StatValue{StatNumber, 3}, nil},
+		{goals, " 12 ", StatValue{StatNumber, 12}, nil},
+		{goals, "2.6", StatValue{StatNumber, 3}, nil},
+		{goals, "", StatValue{StatNumber, 0}, nil},
+		{goals, "far", StatValue{StatNumber, 0}, ErrStatNumber},
+		{goals, "NaN", StatValue{StatNumber, 0}, ErrStatNumber},
+		{distance, "10.5", StatValue{StatNumber, 10.5}, nil},
+		{distance, "7.25", StatValue{StatNumber, 7.3}, nil},
+		{distance, "45.55", StatValue{StatNumber, 45.6}, nil},
+		{distance, "1,5", StatValue{StatNumber, 0}, ErrStatNumber},
+		{SportStat{Type: "number"}, "1.2345", StatValue{StatNumber, 1.2345}, nil},
+		{SportStat{Type: "percentage"}, "45%", StatValue{StatPercentage, 45}, nil},
+		{SportStat{Type: "percentage"}, "101", StatValue{StatPercentage, 0}, ErrStatPercentage},
+		{SportStat{Type: "duration"}, "1:05:30", StatValue{StatDuration, 3930}, nil},
+		{SportStat{Type: "duration"}, "1:75", StatValue{StatDuration, 0}, ErrStatDuration},
+		{SportStat{Type: "duration"}, "", StatValue{StatDuration, 0}, nil},
+		{SportStat{Type: "boolean"}, "on", StatValue{StatBoolean, 1}, nil},
+		{SportStat{Type: "boolean"}, "", StatValue{StatBoolean, 0}, nil},
+		{SportStat{Type: "boolean"}, "maybe", StatValue{StatBoolean, 0}, ErrStatBoolean},
+	}
+	for _, tt := range tests {
+		got, err := tt.stat.Parse(tt.value)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s %q: got error %v, want %v", tt.stat.Type, tt.value, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: got %v, want %v", tt.stat.Type, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestStatValueAdd(t *testing.T) {
+	tests := []struct {
+		v, o StatValue
+		want StatValue
+	}{
+		{StatValue{StatNumber, 1.5}, StatValue{StatNumber, 2}, StatValue{StatNumber, 3.5}},
+		{StatValue{}, StatValue{StatDuration, 65}, StatValue{StatDuration, 65}},
+		{StatValue{StatBoolean, 1}, StatValue{StatBoolean, 1}, StatValue{StatNumber, 2}},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Add(tt.o); got != tt.want {
+			t.Errorf("%v + %v: got %v, want %v", tt.v, tt.o, got, tt.want)
+		}
+	}
+}
+
+func TestStatValueDiv(t *testing.T) {
+	tests := []struct {
+		v    StatValue
+		n    float64
+		want StatValue
+	}{
+		{StatValue{StatNumber, 9}, 2, StatValue{StatNumber, 4.5}},
+		{StatValue{StatNumber, 9}, 0, StatValue{StatNumber, 9}},
+		{StatValue{StatBoolean, 1}, 4, StatValue{StatNumber, 0.25}},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Div(tt.n); got != tt.want {
+			t.Errorf("%v / %v: got %v, want %v", tt.v, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSumStats(t *testing.T) {
+	s1 := StatValues{"g": {StatNumber, 3}, "mvp": {StatBoolean, 1}}
+	s2 := StatValues{"g": {StatNumber, 2}, "t": {StatDuration, 90}}
+	s3 := StatValues{"mvp": {StatBoolean, 1}}
+	want := StatValues{"g": {StatNumber, 5}, "mvp": {StatNumber, 2}, "t": {StatDuration, 90}}
+	if got := SumStats(s1, s2, s3); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := SumStats(); len(got) != 0 {
+		t.Errorf("empty sum: got %v", got)
+	}
+	if s1["g"].Value != 3 {
+		t.Errorf("sum changed its operand: %v", s1)
+	}
+
+	avg := want.Div(2)
+	if avg["g"] != (StatValue{StatNumber, 2.5}) || avg["t"] != (StatValue{StatDuration, 45}) {
+		t.Errorf("got average %v", avg)
+	}
+}
+
+func TestStatValuesJSON(t *testing.T) {
+	stats := []SportStat{
+		{Abbr: "g", Type: "number"},
+		{Abbr: "pct", Type: "percentage"},
+		{Abbr: "t", Type: "duration"},
+		{Abbr: "mvp", Type: "boolean"},
+	}
+	vv := StatValues{
+		"g":   {StatNumber, 3},
+		"pct": {StatPercentage, 45.5},
+		"t":   {StatDuration, 3930},
+		"mvp": {StatBoolean, 1},
+	}
+	b, err := json.Marshal(vv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"g":3,"mvp":true,"pct":45.5,"t":3930}`; string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+	var got StatValues
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got = got.Typed(stats); !reflect.DeepEqual(got, vv) {
+		t.Errorf("got %v, want %v", got, vv)
+	}
+}
+
+func TestStatValueUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want StatValue
+	}{
+		{`null`, StatValue{}},
+		{`false`, StatValue{StatBoolean, 0}},
+		{`true`, StatValue{StatBoolean, 1}},
+		{`7.5`, StatValue{StatNumber, 7.5}},
+		{`"12"`, StatValue{StatNumber, 12}},
+		{`"1:05"`, StatValue{StatDuration, 65}},
+	}
+	for _, tt := range tests {
+		var got StatValue
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.json, got, tt.want)
+		}
+	}
+	for _, s := range []string{`"far"`, `[1]`, `{}`} {
+		var v StatValue
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			t.Errorf("%s: got %v, want an error", s, v)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package pbclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+)
+
+// AuthStore keeps the auth token and record of the authenticated user.
+// OnChange, when set, is called every time the token is saved or cleared so
+// it can be persisted somewhere else (i.e. the user session).
+type AuthStore struct {
+	mu       sync.RWMutex
+	token    string
+	record   UserRecord
+	admin    bool
+	OnChange func(token string, record UserRecord)
+}
+
+func NewAuthStore(token string, record UserRecord) *AuthStore {
+	return &AuthStore{token: token, record: record}
+}
+
+func (s *AuthStore) Token() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.token
+}
+
+func (s *AuthStore) Record() UserRecord {
+	if s == nil {
+		return UserRecord{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.record
+}
+
+func (s *AuthStore) IsAdmin() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.admin
+}
+
+func (s *AuthStore) IsValid() bool {
+	return s.Token() != ""
+}
+
+func (s *AuthStore) Save(token string, record UserRecord) {
+	s.save(token, record, s.IsAdmin())
+}
+
+func (s *AuthStore) Clear() {
+	s.save("", UserRecord{}, false)
+}
+
+func (s *AuthStore) save(token string, record UserRecord, admin bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.token, s.record, s.admin = token, record, admin
+	onChange := s.OnChange
+	s.mu.Unlock()
+	if onChange != nil {
+		onChange(token, record)
+	}
+}
+
+type responseAdminAuth struct {
+	Token string `json:"token"`
+}
+
+// WithAuth returns a copy of the client using the given auth store.
+func (c Client) WithAuth(store *AuthStore) Client {
+	c.Auth = store
+	return c
+}
+
+func (c *Client) AuthRefresh() (*http.Response, error) {
+	return c.AuthRefreshCtx(context.Background())
+}
+
+func (c *Client) AuthRefreshCtx(ctx context.Context) (*http.Response, error) {
+	endpoint := EndpointAuthRefreshUser
+	if c.Auth.IsAdmin() {
+		endpoint = EndpointAuthRefreshAdmin
+	}
+	resp, err := c.RequestCtx(ctx, http.MethodPost, endpoint, QHeaders{"Authorization": c.Auth.Token()})
+	if err != nil {
+		if apiErr, ok := AsAPIError(err); ok && apiErr.StatusCode == http.StatusUnauthorized {
+			c.Auth.Clear()
+		}
+		return resp, err
+	}
+	if c.Auth.IsAdmin() {
+		data := peekJSON[responseAdminAuth](resp)
+		c.Auth.Save(data.Token, UserRecord{})
+	} else {
+		data := peekJSON[ResponseAuth](resp)
+		c.Auth.Save(data.Token, data.Record)
+	}
+	return resp, nil
+}
+
+func (c *Client) shouldRefresh(resp *http.Response, headers QHeaders) bool {
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+	if _, ok := headers["Authorization"]; ok {
+		return false
+	}
+	return c.Auth.IsValid()
+}
+
+// peekJSON decodes the response body without consuming it.
+func peekJSON[T any](resp *http.Response) T {
+	var t T
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		xlog.Error("failed to read response", "error", err)
+		return t
+	}
+	if err = json.Unmarshal(b, &t); err != nil {
+		xlog.Error("failed to unmarshal response", "error", err)
+	}
+	return t
+}
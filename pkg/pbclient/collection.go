@@ -0,0 +1,77 @@
+package pbclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+)
+
+// CollectionClient is a typed handle on a PocketBase collection.
+// Expand, fields, sort and paging are passed as regular IQ options.
+type CollectionClient[T any] struct {
+	Name     string
+	client   *Client
+	ctx      context.Context
+	prefetch bool
+}
+
+func Collection[T any](c *Client, name string) CollectionClient[T] {
+	return CollectionClient[T]{Name: name, client: c, ctx: context.Background()}
+}
+
+// WithContext returns a copy of the collection client whose requests are
+// bound to ctx.
+func (cc CollectionClient[T]) WithContext(ctx context.Context) CollectionClient[T] {
+	cc.ctx = ctx
+	return cc
+}
+
+func (cc CollectionClient[T]) Get(id string, qs ...IQ) (T, error) {
+	return decode[T](cc.client.RecordGetCtx(cc.ctx, cc.Name, id, qs...))
+}
+
+func (cc CollectionClient[T]) List(qs ...IQ) (Records[T], error) {
+	return decode[Records[T]](cc.client.RecordListCtx(cc.ctx, cc.Name, qs...))
+}
+
+// Create creates a record from data. data is sent as JSON unless it's a
+// QMultipart payload.
+func (cc CollectionClient[T]) Create(data any, qs ...IQ) (T, error) {
+	return decode[T](cc.client.RecordCreateCtx(cc.ctx, cc.Name, append(qs, payload(data))...))
+}
+
+// Update updates the record id with data. data is sent as JSON unless it's a
+// QMultipart payload.
+func (cc CollectionClient[T]) Update(id string, data any, qs ...IQ) (T, error) {
+	return decode[T](cc.client.RecordUpdateCtx(cc.ctx, cc.Name, id, append(qs, payload(data))...))
+}
+
+func (cc CollectionClient[T]) Delete(id string) error {
+	resp, err := cc.client.RecordDeleteCtx(cc.ctx, cc.Name, id)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func payload(data any) IQ {
+	if m, ok := data.(QMultipart); ok {
+		return m
+	}
+	return NewQData(data)
+}
+
+func decode[T any](resp *http.Response, err error) (T, error) {
+	var t T
+	if err != nil {
+		return t, err
+	}
+	defer resp.Body.Close()
+	if err = json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		xlog.Error("failed to decode response", "url", resp.Request.URL.String(), "error", err)
+		return t, err
+	}
+	return t, nil
+}
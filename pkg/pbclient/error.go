@@ -0,0 +1,85 @@
+package pbclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+)
+
+// APIError is the error returned by Request for any non 2xx response.
+// It wraps the PocketBase error payload along with the HTTP status code.
+type APIError struct {
+	StatusCode int
+	ResponseError
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s: %d", ErrInvalidStatusCode, e.StatusCode)
+	}
+	return e.Message
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == ErrInvalidStatusCode
+}
+
+// Fields returns the per field validation errors keyed by field name.
+func (e *APIError) Fields() errorsmap.EMap {
+	em := errorsmap.New()
+	for k, v := range e.Data {
+		em[k] = errors.New(v.Message)
+	}
+	return em
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		xlog.Error("failed to read error response", "status-code", resp.StatusCode, "error", err)
+	}
+	// keep the body readable for callers still decoding the response themselves
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &apiErr.ResponseError); err != nil {
+			xlog.Error("failed to decode error response", "status-code", resp.StatusCode, "error", err)
+		}
+	}
+	if apiErr.Code == 0 {
+		apiErr.Code = resp.StatusCode
+	}
+	return apiErr
+}
+
+// AsAPIError returns the APIError wrapped in err if any.
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// ErrorsMap converts err into an errorsmap.EMap. The PocketBase message goes
+// under the "error" key and validation errors under their field name.
+func ErrorsMap(err error) errorsmap.EMap {
+	em := errorsmap.New()
+	if err == nil {
+		return em
+	}
+	em["error"] = err
+	if apiErr, ok := AsAPIError(err); ok {
+		for k, v := range apiErr.Fields() {
+			em[k] = v
+		}
+	}
+	return em
+}
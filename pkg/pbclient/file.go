@@ -0,0 +1,146 @@
+package pbclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/url"
+)
+
+const EndpointFile = "/api/files/%s/%s/%s"
+
+// QFile is a file sent in a multipart payload under the record field Field.
+type QFile struct {
+	Field  string
+	Name   string
+	Reader io.Reader
+}
+
+// QMultipart is a multipart/form-data payload mixing regular record fields
+// with files. It's the payload to use for file fields such as users.avatar.
+type QMultipart struct {
+	Fields map[string]any
+	Files  []QFile
+}
+
+func (q QMultipart) GetQType() string { return QTypeMultipart }
+
+// NewQMultipart builds a multipart payload from data, which is anything
+// marshallable to a JSON object, and files.
+func NewQMultipart(data any, files ...QFile) (QMultipart, error) {
+	q := QMultipart{Fields: map[string]any{}, Files: files}
+	if data == nil {
+		return q, nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return q, err
+	}
+	return q, json.Unmarshal(b, &q.Fields)
+}
+
+func NewQFile(field, name string, r io.Reader) QFile {
+	return QFile{Field: field, Name: name, Reader: r}
+}
+
+// NewQFileHeader opens an uploaded file so it can be forwarded to PocketBase.
+func NewQFileHeader(field string, fh *multipart.FileHeader) (QFile, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return QFile{}, err
+	}
+	defer f.Close()
+	// the file is read right away so it's safe to close the upload
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return QFile{}, err
+	}
+	return NewQFile(field, fh.Filename, bytes.NewReader(b)), nil
+}
+
+// Encode returns the multipart body along with its content type.
+func (q QMultipart) Encode() ([]byte, string, error) {
+	var (
+		buf bytes.Buffer
+		w   = multipart.NewWriter(&buf)
+	)
+	for k, v := range q.Fields {
+		for _, s := range multipartValues(v) {
+			if err := w.WriteField(k, s); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for _, f := range q.Files {
+		part, err := w.CreateFormFile(f.Field, f.Name)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = io.Copy(part, f.Reader); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+func multipartValues(v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return []string{""}
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		ss := make([]string, 0, len(v))
+		for _, i := range v {
+			ss = append(ss, multipartValues(i)...)
+		}
+		return ss
+	case map[string]any:
+		b, _ := json.Marshal(v)
+		return []string{string(b)}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// FilePath returns the path serving filename of a record. A non empty thumb
+// (i.e. "100x100") returns the path of the matching thumbnail.
+func FilePath(collection, recordID, filename, thumb string) string {
+	if filename == "" {
+		return ""
+	}
+	p := getEndpoint(EndpointFile, url.PathEscape(collection), url.PathEscape(recordID), url.PathEscape(filename))
+	if thumb != "" {
+		p += "?" + url.Values{"thumb": {thumb}}.Encode()
+	}
+	return p
+}
+
+// FileURL returns the absolute url of a record file.
+func (c Client) FileURL(collection, recordID, filename string) string {
+	return c.ThumbURL(collection, recordID, filename, "")
+}
+
+// ThumbURL returns the absolute url of a record file thumbnail.
+func (c Client) ThumbURL(collection, recordID, filename, thumb string) string {
+	p := FilePath(collection, recordID, filename, thumb)
+	if p == "" {
+		return ""
+	}
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return p
+	}
+	ref, err := url.Parse(p)
+	if err != nil {
+		return p
+	}
+	return base.ResolveReference(ref).String()
+}
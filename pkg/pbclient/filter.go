@@ -0,0 +1,132 @@
+package pbclient
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateTimeLayout is the datetime format used by PocketBase.
+const DateTimeLayout = "2006-01-02 15:04:05.000Z"
+
+// fieldRgx matches field names and relation paths such as group.sport.name,
+// members_via_group.username or tags:each.
+var fieldRgx = regexp.MustCompile(`^@?[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*(:[a-z]+)?$`)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Filter is a filter expression built by Eq, In, And... The first invalid
+// field name or value met while building it is kept and returned by the
+// request sending it, instead of a malformed filter.
+type Filter struct {
+	expr string
+	err  error
+}
+
+func (f Filter) GetQType() string { return QTypeFilters }
+
+// String returns the filter expression.
+func (f Filter) String() string { return f.expr }
+
+// Err returns the error met while building f, if any.
+func (f Filter) Err() error { return f.err }
+
+func field(name string) error {
+	if !fieldRgx.MatchString(name) {
+		return fmt.Errorf("%w: field %q", ErrInvalidFilter, name)
+	}
+	return nil
+}
+
+// Quote returns v as a PocketBase filter literal.
+// Strings are single quoted and escaped so they can't close the literal.
+// A string ending with a backslash can't be written as a literal, the
+// filter syntax having no escape for a backslash before the closing quote:
+// Quote returns an error wrapping ErrInvalidFilter then.
+func Quote(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return quoteString(v.UTC().Format(DateTimeLayout))
+	case fmt.Stringer:
+		return quoteString(v.String())
+	case string:
+		return quoteString(v)
+	default:
+		return quoteString(fmt.Sprint(v))
+	}
+}
+
+// quoteString escapes the quotes of s. The scanner only unescapes \' so
+// the other backslashes, a\' included, are read back as they are.
+func quoteString(s string) (string, error) {
+	if strings.HasSuffix(s, `\`) {
+		return "", fmt.Errorf("%w: value %q ends with a backslash", ErrInvalidFilter, s)
+	}
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'", nil
+}
+
+func compare(name, op string, value any) Filter {
+	if err := field(name); err != nil {
+		return Filter{err: err}
+	}
+	v, err := Quote(value)
+	if err != nil {
+		return Filter{err: err}
+	}
+	return Filter{expr: name + op + v}
+}
+
+func Eq(name string, value any) Filter   { return compare(name, "=", value) }
+func Neq(name string, value any) Filter  { return compare(name, "!=", value) }
+func Like(name string, value any) Filter { return compare(name, "~", value) }
+func Gt(name string, value any) Filter   { return compare(name, ">", value) }
+func Gte(name string, value any) Filter  { return compare(name, ">=", value) }
+func Lt(name string, value any) Filter   { return compare(name, "<", value) }
+func Lte(name string, value any) Filter  { return compare(name, "<=", value) }
+
+// In matches any of the values. An empty list matches nothing.
+func In[T any](name string, values ...T) Filter {
+	if len(values) == 0 {
+		return And(Eq(name, ""), Neq(name, ""))
+	}
+	ff := make([]Filter, len(values))
+	for i, v := range values {
+		ff[i] = Eq(name, v)
+	}
+	return Or(ff...)
+}
+
+func And(ff ...Filter) Filter { return join("&&", ff) }
+func Or(ff ...Filter) Filter  { return join("||", ff) }
+
+func join(op string, ff []Filter) Filter {
+	parts := make([]string, 0, len(ff))
+	for _, f := range ff {
+		if f.err != nil {
+			return f
+		}
+		if f.expr != "" {
+			parts = append(parts, f.expr)
+		}
+	}
+	switch len(parts) {
+	case 0:
+		return Filter{}
+	case 1:
+		return Filter{expr: parts[0]}
+	}
+	return Filter{expr: "(" + strings.Join(parts, op) + ")"}
+}
@@ -0,0 +1,96 @@
+package pbclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ganigeorgiev/fexpr"
+)
+
+func TestQuoteRoundTrip(t *testing.T) {
+	for _, s := range []string{"", "abc", "it's", `a\b`, `a\'b`, `\'`, `'`, `\\'x`, "a'||1=1||'"} {
+		f := Eq("name", s)
+		if f.Err() != nil {
+			t.Fatalf("Eq(%q): %v", s, f.Err())
+		}
+		groups, err := fexpr.Parse(f.String())
+		if err != nil {
+			t.Fatalf("parse %s: %v", f, err)
+		}
+		if len(groups) != 1 {
+			t.Fatalf("%s: got %d groups, want 1", f, len(groups))
+		}
+		expr := groups[0].Item.(fexpr.Expr)
+		if expr.Left.Literal != "name" || expr.Right.Type != fexpr.TokenText || expr.Right.Literal != s {
+			t.Errorf("%s: read back %q, want %q", f, expr.Right.Literal, s)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	date := time.Date(2024, 5, 1, 10, 0, 0, 0, time.FixedZone("", 3600))
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{1.5, "1.5"},
+		{date, "'2024-05-01 09:00:00.000Z'"},
+		{"o'k", `'o\'k'`},
+	}
+	for _, tt := range tests {
+		got, err := Quote(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("Quote(%v) = %s, %v, want %s", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	tests := map[string]Filter{
+		"field":             Eq("name) || (1", "x"),
+		"trailing slash":    Eq("name", `abc\`),
+		"in field":          In("a b", "x"),
+		"and keeps error":   And(Eq("ok", 1), Like("bad field", "x")),
+		"or keeps error":    Or(Eq("ok", 1), Eq("ok", `x\`)),
+		"empty in, invalid": In[string]("@@"),
+	}
+	for name, f := range tests {
+		if !errors.Is(f.Err(), ErrInvalidFilter) {
+			t.Errorf("%s: got %v, want ErrInvalidFilter", name, f.Err())
+		}
+		if f.String() != "" {
+			t.Errorf("%s: got expression %q", name, f)
+		}
+	}
+}
+
+func TestFilterJoin(t *testing.T) {
+	tests := []struct {
+		f    Filter
+		want string
+	}{
+		{And(), ""},
+		{And(Eq("a", 1)), "a=1"},
+		{And(Eq("a", 1), Filter{}, Neq("b", "x")), "(a=1&&b!='x')"},
+		{Or(Gt("a", 1), Lte("group.sport.name", "x")), "(a>1||group.sport.name<='x')"},
+		{In("id", "a", "b"), "(id='a'||id='b')"},
+		{In[string]("id"), "(id=''&&id!='')"},
+	}
+	for _, tt := range tests {
+		if tt.f.Err() != nil || tt.f.String() != tt.want {
+			t.Errorf("got %q, %v, want %q", tt.f, tt.f.Err(), tt.want)
+		}
+	}
+}
+
+func TestRequestInvalidFilter(t *testing.T) {
+	c := New("http://127.0.0.1:0")
+	_, err := c.Request("GET", "/api/collections/groups/records", Eq("bad field", 1))
+	if !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("got %v, want ErrInvalidFilter", err)
+	}
+}
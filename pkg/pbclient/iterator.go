@@ -0,0 +1,95 @@
+package pbclient
+
+const DefaultPerPage = 200
+
+type pageResult[T any] struct {
+	records Records[T]
+	err     error
+}
+
+// WithPrefetch returns a copy of the collection client fetching the next
+// page concurrently while the current one is being walked by Each.
+func (cc CollectionClient[T]) WithPrefetch() CollectionClient[T] {
+	cc.prefetch = true
+	return cc
+}
+
+// Each walks every record of every page matching qs. Paging is driven by
+// the QPage option if any, otherwise DefaultPerPage records are fetched per
+// page. Totals are skipped. fn returning false stops the iteration, and so
+// does an error which is passed to fn with a zero value.
+func (cc CollectionClient[T]) Each(fn func(T, error) bool, qs ...IQ) {
+	var (
+		page = QPage{Page: 1, PerPage: DefaultPerPage}
+		opts = make([]IQ, 0, len(qs))
+	)
+	for _, q := range qs {
+		if p, ok := q.(QPage); ok {
+			page.Page, page.PerPage = max(p.Page, 1), p.PerPage
+			if page.PerPage <= 0 {
+				page.PerPage = DefaultPerPage
+			}
+			continue
+		}
+		opts = append(opts, q)
+	}
+	page.SkipTotal = true
+
+	fetch := func(p QPage) <-chan pageResult[T] {
+		ch := make(chan pageResult[T], 1)
+		run := func() {
+			records, err := cc.List(append(opts, p)...)
+			ch <- pageResult[T]{records: records, err: err}
+		}
+		if cc.prefetch {
+			go run()
+		} else {
+			run()
+		}
+		return ch
+	}
+
+	next := fetch(page)
+	for {
+		res := <-next
+		if res.err != nil {
+			var t T
+			fn(t, res.err)
+			return
+		}
+		last := len(res.records.Items) < page.PerPage
+		if !last && cc.prefetch {
+			page.Page++
+			next = fetch(page)
+		}
+		for _, item := range res.records.Items {
+			if !fn(item, nil) {
+				return
+			}
+		}
+		if last {
+			return
+		}
+		if !cc.prefetch {
+			page.Page++
+			next = fetch(page)
+		}
+	}
+}
+
+// ListAll returns every record matching qs across all pages.
+func (cc CollectionClient[T]) ListAll(qs ...IQ) ([]T, error) {
+	var (
+		items = []T{}
+		err   error
+	)
+	cc.Each(func(t T, e error) bool {
+		if e != nil {
+			err = e
+			return false
+		}
+		items = append(items, t)
+		return true
+	}, qs...)
+	return items, err
+}
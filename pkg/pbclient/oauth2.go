@@ -0,0 +1,77 @@
+package pbclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+const (
+	EndpointAuthMethods = "/api/collections/users/auth-methods"
+	EndpointAuthOAuth2  = "/api/collections/users/auth-with-oauth2"
+)
+
+type AuthProvider struct {
+	Name                string `json:"name"`
+	DisplayName         string `json:"displayName"`
+	State               string `json:"state"`
+	AuthURL             string `json:"authUrl"`
+	CodeVerifier        string `json:"codeVerifier"`
+	CodeChallenge       string `json:"codeChallenge"`
+	CodeChallengeMethod string `json:"codeChallengeMethod"`
+}
+
+// RedirectURL returns the provider authorization url redirecting the user
+// back to redirectURL. PocketBase leaves the redirect_uri param empty for it.
+func (p AuthProvider) RedirectURL(redirectURL string) string {
+	return p.AuthURL + url.QueryEscape(redirectURL)
+}
+
+type ResponseAuthMethods struct {
+	UsernamePassword bool           `json:"usernamePassword"`
+	EmailPassword    bool           `json:"emailPassword"`
+	OnlyVerified     bool           `json:"onlyVerified"`
+	AuthProviders    []AuthProvider `json:"authProviders"`
+}
+
+// Provider returns the auth provider named name if enabled.
+func (r ResponseAuthMethods) Provider(name string) (AuthProvider, bool) {
+	for _, p := range r.AuthProviders {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return AuthProvider{}, false
+}
+
+type RequestOAuth2 struct {
+	Provider     string         `json:"provider"`
+	Code         string         `json:"code"`
+	CodeVerifier string         `json:"codeVerifier"`
+	RedirectURL  string         `json:"redirectUrl"`
+	CreateData   map[string]any `json:"createData,omitempty"`
+}
+
+func (c *Client) AuthMethods() (ResponseAuthMethods, error) {
+	return c.AuthMethodsCtx(context.Background())
+}
+
+func (c *Client) AuthMethodsCtx(ctx context.Context) (ResponseAuthMethods, error) {
+	return decode[ResponseAuthMethods](c.RequestCtx(ctx, http.MethodGet, EndpointAuthMethods))
+}
+
+func (c *Client) AuthWithOAuth2(req RequestOAuth2) (*http.Response, error) {
+	return c.AuthWithOAuth2Ctx(context.Background(), req)
+}
+
+// AuthWithOAuth2Ctx exchanges the authorization code sent back by the
+// provider for a PocketBase token saved in the client auth store.
+func (c *Client) AuthWithOAuth2Ctx(ctx context.Context, req RequestOAuth2) (*http.Response, error) {
+	resp, err := c.RequestCtx(ctx, http.MethodPost, EndpointAuthOAuth2, NewQData(req))
+	if err != nil {
+		return resp, err
+	}
+	data := peekJSON[ResponseAuth](resp)
+	c.Auth.save(data.Token, data.Record, false)
+	return resp, nil
+}
@@ -1,23 +1,31 @@
 package pbclient
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/davesavic/clink"
 	"github.com/josuebrunel/sportdropin/pkg/xlog"
 )
 
 const (
-	EndpointAuthAdmin = "/api/admin/auth-with-password"
-	EndpointAuthUser  = "/api/collections/users/auth-with-password"
-	EndpointRecords   = "/api/collections/%s/records"
-	EndpointRecordID  = "/api/collections/%s/records/%s"
+	EndpointAuthAdmin        = "/api/admins/auth-with-password"
+	EndpointAuthUser         = "/api/collections/users/auth-with-password"
+	EndpointAuthRefreshAdmin = "/api/admins/auth-refresh"
+	EndpointAuthRefreshUser  = "/api/collections/users/auth-refresh"
+	EndpointRecords          = "/api/collections/%s/records"
+	EndpointRecordID         = "/api/collections/%s/records/%s"
 )
 
+const DefaultTimeout = 30 * time.Second
+
 var ErrInvalidStatusCode = errors.New("invalid status code")
 
 func getEndpoint(endpoint string, params ...any) string {
@@ -27,16 +35,24 @@ func getEndpoint(endpoint string, params ...any) string {
 type (
 	Client struct {
 		BaseURL string
-		Token   string
+		Auth    *AuthStore
 		Client  *clink.Client
+		// Timeout bounds each HTTP call, zero means no timeout
+		Timeout time.Duration
+		Retry   RetryPolicy
 	}
 	Payload = map[string]string
 )
 
 func New(baseURL string) Client {
 	c := clink.NewClient()
-	c.Headers["Content-Type"] = "application/json"
-	return Client{BaseURL: baseURL, Client: c}
+	return Client{
+		BaseURL: baseURL,
+		Auth:    NewAuthStore("", UserRecord{}),
+		Client:  c,
+		Timeout: DefaultTimeout,
+		Retry:   DefaultRetryPolicy,
+	}
 }
 
 func (c Client) buildUrl(path string, qms ...IQ) string {
@@ -70,7 +86,13 @@ func (c Client) buildUrl(path string, qms ...IQ) string {
 		case QSort:
 			q.Set("sort", QmListString(qm))
 		case QFilters:
-			q.Set("filters", string(qm))
+			if qm != "" {
+				q.Set("filter", string(qm))
+			}
+		case Filter:
+			if qm.expr != "" {
+				q.Set("filter", qm.expr)
+			}
 		case QPage:
 			q.Set("page", strconv.Itoa(qm.Page))
 			q.Set("perPage", strconv.Itoa(qm.PerPage))
@@ -84,74 +106,218 @@ func (c Client) buildUrl(path string, qms ...IQ) string {
 }
 
 func (c *Client) Request(method, url string, args ...IQ) (*http.Response, error) {
+	return c.RequestCtx(context.Background(), method, url, args...)
+}
+
+// RequestCtx sends the request bound to ctx. Cancelling ctx cancels the
+// underlying HTTP call as well as any pending retry.
+func (c *Client) RequestCtx(ctx context.Context, method, url string, args ...IQ) (*http.Response, error) {
+	for _, arg := range args {
+		if f, ok := arg.(Filter); ok && f.err != nil {
+			xlog.Error("invalid filter", "url", url, "error", f.err)
+			return nil, f.err
+		}
+	}
 	url = c.buildUrl(url, args...)
 	var (
-		body    QData
-		headers = QHeaders{}
+		body      QData
+		multi     *QMultipart
+		headers   = QHeaders{}
+		reqHeader = QHeaders{}
+		payload   []byte
+		err       error
 	)
 	for _, arg := range args {
 		switch arg := arg.(type) {
 		case QData:
 			body = arg
+		case QMultipart:
+			multi = &arg
 		case QHeaders:
 			headers = arg
 		}
 	}
 	xlog.Debug("request attr", "url", url, "headers", headers, "payload", body.Data)
-	req, err := http.NewRequest(method, url, body.DataBytes)
-	for key, val := range headers {
-		req.Header.Set(key, val)
+	switch {
+	case multi != nil:
+		var contentType string
+		if payload, contentType, err = multi.Encode(); err != nil {
+			xlog.Error("failed to encode multipart payload", "url", url, "error", err)
+			return nil, err
+		}
+		reqHeader["Content-Type"] = contentType
+	case body.DataBytes != nil:
+		if payload, err = io.ReadAll(body.DataBytes); err != nil {
+			xlog.Error("failed to read payload", "url", url, "error", err)
+			return nil, err
+		}
+		reqHeader["Content-Type"] = "application/json"
+	}
+	for k, v := range headers {
+		reqHeader[k] = v
+	}
+	headers = reqHeader
+	resp, err := c.send(ctx, method, url, payload, headers)
+	if err == nil || !c.shouldRefresh(resp, headers) {
+		return resp, err
 	}
+	xlog.Debug("token rejected, refreshing", "url", url)
+	if _, rerr := c.AuthRefreshCtx(ctx); rerr != nil {
+		xlog.Error("failed to refresh token", "url", url, "error", rerr)
+		return resp, err
+	}
+	return c.send(ctx, method, url, payload, headers)
+}
+
+// send calls do, retrying according to the client retry policy.
+func (c *Client) send(ctx context.Context, method, url string, payload []byte, headers QHeaders) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, method, url, payload, headers)
+		if attempt >= c.Retry.MaxRetries || ctx.Err() != nil || !c.Retry.retryable(method, err) {
+			return resp, err
+		}
+		delay := c.Retry.backoff(attempt, resp)
+		xlog.Warn("retrying request", "url", url, "attempt", attempt+1, "delay", delay, "error", err)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, url string, payload []byte, headers QHeaders) (*http.Response, error) {
+	var (
+		body   io.Reader
+		cancel = func() {}
+	)
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
+		cancel()
 		xlog.Error("failed to prepare request", "url", url)
 		return nil, err
 	}
+	if token := c.Auth.Token(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	for key, val := range headers {
+		req.Header.Set(key, val)
+	}
 	xlog.Debug("calling", "url", req.URL.String())
 	resp, err := c.Client.Do(req)
 	if err != nil {
-		xlog.Error("error while trying to fetch url", "url", url, "status-code", resp.StatusCode)
+		cancel()
+		xlog.Error("error while trying to fetch url", "url", url, "error", err)
 		return resp, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return resp, ErrInvalidStatusCode
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		apiErr := newAPIError(resp)
+		cancel()
+		xlog.Error("unexpected status code", "url", url, "status-code", resp.StatusCode, "message", apiErr.Message)
+		return resp, apiErr
 	}
+	// the timeout must outlive the call so the body can still be read
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
 	return resp, nil
 }
 
-func (c *Client) Auth(endpoint, username, password string) (*http.Response, error) {
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (c *Client) Authenticate(endpoint, username, password string) (*http.Response, error) {
+	return c.AuthenticateCtx(context.Background(), endpoint, username, password)
+}
+
+func (c *Client) AuthenticateCtx(ctx context.Context, endpoint, username, password string) (*http.Response, error) {
 	payload := RequestAuth{
 		Identity: username,
 		Password: password,
 	}
-	return c.Request(http.MethodPost, endpoint, NewQData(payload))
+	return c.RequestCtx(ctx, http.MethodPost, endpoint, NewQData(payload))
 }
 
 func (c *Client) AdminAuth(username, password string) (*http.Response, error) {
-	return c.Auth(EndpointAuthAdmin, username, password)
+	return c.AdminAuthCtx(context.Background(), username, password)
+}
+
+func (c *Client) AdminAuthCtx(ctx context.Context, username, password string) (*http.Response, error) {
+	resp, err := c.AuthenticateCtx(ctx, EndpointAuthAdmin, username, password)
+	if err != nil {
+		return resp, err
+	}
+	data := peekJSON[responseAdminAuth](resp)
+	c.Auth.save(data.Token, UserRecord{}, true)
+	return resp, nil
 }
 
 func (c *Client) UserAuth(username, password string) (*http.Response, error) {
-	return c.Auth(EndpointAuthUser, username, password)
+	return c.UserAuthCtx(context.Background(), username, password)
+}
+
+func (c *Client) UserAuthCtx(ctx context.Context, username, password string) (*http.Response, error) {
+	resp, err := c.AuthenticateCtx(ctx, EndpointAuthUser, username, password)
+	if err != nil {
+		return resp, err
+	}
+	data := peekJSON[ResponseAuth](resp)
+	c.Auth.save(data.Token, data.Record, false)
+	return resp, nil
 }
 
 func (c *Client) RecordCreate(name string, qs ...IQ) (*http.Response, error) {
-	return c.Request(http.MethodPost, EndpointRecords, append(qs, QParams{name})...)
+	return c.RecordCreateCtx(context.Background(), name, qs...)
+}
+
+func (c *Client) RecordCreateCtx(ctx context.Context, name string, qs ...IQ) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPost, EndpointRecords, append(qs, QParams{name})...)
 }
 
 func (c *Client) RecordGet(name string, id string, qs ...IQ) (*http.Response, error) {
-	return c.Request(http.MethodGet, EndpointRecordID, append(qs, QParams{name, id})...)
+	return c.RecordGetCtx(context.Background(), name, id, qs...)
+}
+
+func (c *Client) RecordGetCtx(ctx context.Context, name string, id string, qs ...IQ) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodGet, EndpointRecordID, append(qs, QParams{name, id})...)
 }
 
 func (c *Client) RecordList(name string, qs ...IQ) (*http.Response, error) {
-	return c.Request(http.MethodGet, EndpointRecords, append(qs, QParams{name})...)
+	return c.RecordListCtx(context.Background(), name, qs...)
+}
+
+func (c *Client) RecordListCtx(ctx context.Context, name string, qs ...IQ) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodGet, EndpointRecords, append(qs, QParams{name})...)
 }
 
 func (c *Client) RecordUpdate(name string, id string, qs ...IQ) (*http.Response, error) {
-	return c.Request(http.MethodPatch, EndpointRecordID, append(qs, QParams{name, id})...)
+	return c.RecordUpdateCtx(context.Background(), name, id, qs...)
+}
+
+func (c *Client) RecordUpdateCtx(ctx context.Context, name string, id string, qs ...IQ) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPatch, EndpointRecordID, append(qs, QParams{name, id})...)
 }
 
 func (c *Client) RecordDelete(name string, id string) (*http.Response, error) {
-	return c.Request(http.MethodDelete, EndpointRecordID, QParams{name, id})
+	return c.RecordDeleteCtx(context.Background(), name, id)
+}
+
+func (c *Client) RecordDeleteCtx(ctx context.Context, name string, id string) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodDelete, EndpointRecordID, QParams{name, id})
 }
 
 func ResponseTo[T any](resp *http.Response) T {
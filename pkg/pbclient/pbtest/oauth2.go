@@ -0,0 +1,101 @@
+package pbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/josuebrunel/sportdropin/pkg/pbclient"
+)
+
+type oauth2Code struct {
+	provider     string
+	codeVerifier string
+}
+
+// OAuth2Provider enables a fake OAuth2 provider named name. Its authorize
+// endpoint is served by the fake server itself and immediately redirects
+// back with a code signing in user, which is created on first sign in.
+func (s *Server) OAuth2Provider(name string, user Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.providers[name] = copyRecord(user)
+}
+
+func (s *Server) authMethods(w http.ResponseWriter) {
+	methods := pbclient.ResponseAuthMethods{
+		UsernamePassword: true,
+		EmailPassword:    true,
+		AuthProviders:    []pbclient.AuthProvider{},
+	}
+	for name := range s.providers {
+		s.seq++
+		state := fmt.Sprintf("state-%d", s.seq)
+		p := pbclient.AuthProvider{
+			Name:                name,
+			DisplayName:         name,
+			State:               state,
+			CodeVerifier:        fmt.Sprintf("verifier-%d", s.seq),
+			CodeChallengeMethod: "S256",
+		}
+		p.CodeChallenge = p.CodeVerifier
+		p.AuthURL = fmt.Sprintf("%s/oauth2/%s/authorize?%s&redirect_uri=", s.URL, name, url.Values{
+			"client_id":      {"pbtest"},
+			"state":          {state},
+			"code_challenge": {p.CodeChallenge},
+		}.Encode())
+		s.states[state] = oauth2Code{provider: name, codeVerifier: p.CodeVerifier}
+		methods.AuthProviders = append(methods.AuthProviders, p)
+	}
+	writeJSON(w, http.StatusOK, methods)
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, provider string) {
+	q := r.URL.Query()
+	pending, ok := s.states[q.Get("state")]
+	if !ok || pending.provider != provider || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	delete(s.states, q.Get("state"))
+	s.seq++
+	code := fmt.Sprintf("code-%d", s.seq)
+	s.codes[code] = pending
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) authWithOAuth2(w http.ResponseWriter, collection string, body []byte) {
+	var req pbclient.RequestOAuth2
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to authenticate.", nil)
+		return
+	}
+	issued, ok := s.codes[req.Code]
+	if !ok || issued.provider != req.Provider || issued.codeVerifier != req.CodeVerifier || req.RedirectURL == "" {
+		writeError(w, http.StatusBadRequest, "Failed to authenticate.", nil)
+		return
+	}
+	delete(s.codes, req.Code)
+	user := s.providers[req.Provider]
+	for _, r := range s.collections[collection] {
+		if r["email"] == user["email"] {
+			s.writeAuth(w, collection, r)
+			return
+		}
+	}
+	data := copyRecord(user)
+	for k, v := range req.CreateData {
+		data[k] = v
+	}
+	s.writeAuth(w, collection, s.insert(collection, data))
+}
@@ -0,0 +1,324 @@
+package pbtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ganigeorgiev/fexpr"
+)
+
+// target returns the collection a relation field of collection points to.
+func (s *Server) target(collection, field string) string {
+	if t, ok := s.relations[collection+"."+field]; ok {
+		return t
+	}
+	if _, ok := s.collections[field+"s"]; ok {
+		return field + "s"
+	}
+	return field
+}
+
+func (s *Server) find(collection, id string) Record {
+	if idx := s.index(collection, id); idx >= 0 {
+		return s.collections[collection][idx]
+	}
+	return nil
+}
+
+// related returns the records pointed by field of r. Back relations are
+// written as <collection>_via_<field> like PocketBase does.
+func (s *Server) related(collection string, r Record, field string) ([]Record, string, bool) {
+	if coll, via, ok := strings.Cut(field, "_via_"); ok {
+		rr := []Record{}
+		for _, c := range s.collections[coll] {
+			if containsID(c[via], r["id"]) {
+				rr = append(rr, c)
+			}
+		}
+		return rr, coll, true
+	}
+	target := s.target(collection, field)
+	switch v := r[field].(type) {
+	case string:
+		if rel := s.find(target, v); rel != nil {
+			return []Record{rel}, target, false
+		}
+		return nil, target, false
+	case []any, []string:
+		rr := []Record{}
+		for _, id := range ids(v) {
+			if rel := s.find(target, id); rel != nil {
+				rr = append(rr, rel)
+			}
+		}
+		return rr, target, true
+	}
+	return nil, target, false
+}
+
+func (s *Server) expand(collection string, r Record, expand string) Record {
+	tree := map[string][]string{}
+	for _, path := range strings.Split(expand, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		head, rest, _ := strings.Cut(path, ".")
+		tree[head] = append(tree[head], rest)
+	}
+	out := Record{}
+	for field, nested := range tree {
+		rr, target, many := s.related(collection, r, field)
+		if len(rr) == 0 {
+			continue
+		}
+		sub := strings.Join(nested, ",")
+		views := make([]Record, 0, len(rr))
+		for _, rel := range rr {
+			v := copyRecord(rel)
+			delete(v, "password")
+			if sub != "" {
+				if e := s.expand(target, rel, sub); len(e) > 0 {
+					v["expand"] = e
+				}
+			}
+			views = append(views, v)
+		}
+		if many {
+			out[field] = views
+		} else {
+			out[field] = views[0]
+		}
+	}
+	return out
+}
+
+// resolve returns the values of a possibly dotted field path of r.
+func (s *Server) resolve(collection string, r Record, path string) []any {
+	head, rest, nested := strings.Cut(path, ".")
+	if !nested {
+		return []any{r[head]}
+	}
+	rr, target, _ := s.related(collection, r, head)
+	values := []any{}
+	for _, rel := range rr {
+		values = append(values, s.resolve(target, rel, rest)...)
+	}
+	if len(values) == 0 {
+		values = append(values, nil)
+	}
+	return values
+}
+
+func (s *Server) match(collection string, r Record, filter string) (bool, error) {
+	if strings.TrimSpace(filter) == "" {
+		return true, nil
+	}
+	groups, err := fexpr.Parse(filter)
+	if err != nil {
+		return false, err
+	}
+	return s.evalGroups(collection, r, groups)
+}
+
+func (s *Server) evalGroups(collection string, r Record, groups []fexpr.ExprGroup) (bool, error) {
+	var result bool
+	for i, g := range groups {
+		var (
+			ok  bool
+			err error
+		)
+		switch item := g.Item.(type) {
+		case fexpr.Expr:
+			ok, err = s.evalExpr(collection, r, item)
+		case fexpr.ExprGroup:
+			ok, err = s.evalGroups(collection, r, []fexpr.ExprGroup{item})
+		case []fexpr.ExprGroup:
+			ok, err = s.evalGroups(collection, r, item)
+		default:
+			err = fmt.Errorf("unsupported filter item %T", item)
+		}
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case i == 0:
+			result = ok
+		case g.Join == fexpr.JoinOr:
+			result = result || ok
+		default:
+			result = result && ok
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) operand(collection string, r Record, t fexpr.Token) []any {
+	switch t.Type {
+	case fexpr.TokenText:
+		return []any{t.Literal}
+	case fexpr.TokenNumber:
+		f, _ := strconv.ParseFloat(t.Literal, 64)
+		return []any{f}
+	case fexpr.TokenIdentifier:
+		switch t.Literal {
+		case "null":
+			return []any{nil}
+		case "true":
+			return []any{true}
+		case "false":
+			return []any{false}
+		}
+		return s.resolve(collection, r, strings.TrimSuffix(t.Literal, ":each"))
+	}
+	return []any{nil}
+}
+
+func (s *Server) evalExpr(collection string, r Record, e fexpr.Expr) (bool, error) {
+	var (
+		left  = s.operand(collection, r, e.Left)
+		right = s.operand(collection, r, e.Right)
+		op    = strings.TrimPrefix(string(e.Op), "?")
+	)
+	for _, l := range left {
+		for _, rv := range right {
+			ok, err := compare(l, fexpr.SignOp(op), rv)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+func compare(l any, op fexpr.SignOp, r any) (bool, error) {
+	switch op {
+	case fexpr.SignEq:
+		return equal(l, r), nil
+	case fexpr.SignNeq:
+		return !equal(l, r), nil
+	case fexpr.SignLike:
+		return like(l, r), nil
+	case fexpr.SignNlike:
+		return !like(l, r), nil
+	case fexpr.SignLt:
+		return order(l, r) < 0, nil
+	case fexpr.SignLte:
+		return order(l, r) <= 0, nil
+	case fexpr.SignGt:
+		return order(l, r) > 0, nil
+	case fexpr.SignGte:
+		return order(l, r) >= 0, nil
+	}
+	return false, fmt.Errorf("unsupported operator %q", op)
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func equal(l, r any) bool {
+	if lf, ok := toFloat(l); ok {
+		if rf, ok := toFloat(r); ok {
+			return lf == rf
+		}
+	}
+	return toString(l) == toString(r)
+}
+
+func like(l, r any) bool {
+	needle := strings.ToLower(strings.Trim(toString(r), "%"))
+	return strings.Contains(strings.ToLower(toString(l)), needle)
+}
+
+func order(l, r any) int {
+	if lf, ok := toFloat(l); ok {
+		if rf, ok := toFloat(r); ok {
+			switch {
+			case lf < rf:
+				return -1
+			case lf > rf:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(toString(l), toString(r))
+}
+
+func (s *Server) sort(collection string, rr []Record, spec string) {
+	if spec == "" {
+		return
+	}
+	keys := strings.Split(spec, ",")
+	sort.SliceStable(rr, func(i, j int) bool {
+		for _, k := range keys {
+			k = strings.TrimSpace(k)
+			if k == "" {
+				continue
+			}
+			desc := strings.HasPrefix(k, "-")
+			k = strings.TrimLeft(k, "+-")
+			c := order(s.resolve(collection, rr[i], k)[0], s.resolve(collection, rr[j], k)[0])
+			if c == 0 {
+				continue
+			}
+			return (c < 0) != desc
+		}
+		return false
+	})
+}
+
+func ids(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		ss := make([]string, 0, len(v))
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	}
+	return nil
+}
+
+func containsID(v any, id any) bool {
+	for _, i := range ids(v) {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
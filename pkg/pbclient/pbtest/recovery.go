@@ -0,0 +1,100 @@
+package pbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/josuebrunel/sportdropin/pkg/pbclient"
+)
+
+// Mail actions.
+const (
+	MailPasswordReset = "password-reset"
+	MailVerification  = "verification"
+)
+
+// Mail is an email sent by the fake server, Token being the token of its
+// link.
+type Mail struct {
+	To     string
+	Action string
+	Token  string
+}
+
+type mailToken struct {
+	collection string
+	action     string
+	id         string
+}
+
+// Mails returns the emails sent so far.
+func (s *Server) Mails() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail{}, s.mails...)
+}
+
+// requestMail sends the email of action to the record of collection
+// registered with the email of the request, if any. As PocketBase, it
+// answers the same whether the email is registered or not.
+func (s *Server) requestMail(w http.ResponseWriter, collection, action string, body []byte) {
+	var req pbclient.RequestEmail
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to load the submitted data due to invalid formatting.", nil)
+		return
+	}
+	if req.Email == "" {
+		writeError(w, http.StatusBadRequest, "An error occurred while validating the submitted data.", map[string]pbclient.ResponseFieldError{
+			"email": {Code: "validation_required", Message: "Missing required value."},
+		})
+		return
+	}
+	for _, r := range s.collections[collection] {
+		if r["email"] != req.Email {
+			continue
+		}
+		if verified, _ := r["verified"].(bool); action == MailVerification && verified {
+			break
+		}
+		s.seq++
+		token := fmt.Sprintf("pbtest-%s-%s-%d", action, r["id"], s.seq)
+		s.mailTokens[token] = mailToken{collection: collection, action: action, id: r["id"].(string)}
+		s.mails = append(s.mails, Mail{To: req.Email, Action: action, Token: token})
+		break
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// confirmMail applies the action of the token of the request, which is
+// then spent.
+func (s *Server) confirmMail(w http.ResponseWriter, collection, action string, body []byte) {
+	var data Record
+	if err := json.Unmarshal(body, &data); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to load the submitted data due to invalid formatting.", nil)
+		return
+	}
+	token, _ := data["token"].(string)
+	mt, ok := s.mailTokens[token]
+	idx := s.index(collection, mt.id)
+	if !ok || mt.collection != collection || mt.action != action || idx < 0 {
+		writeError(w, http.StatusBadRequest, "An error occurred while validating the submitted data.", map[string]pbclient.ResponseFieldError{
+			"token": {Code: "validation_invalid_token", Message: "Invalid or expired token."},
+		})
+		return
+	}
+	record := s.collections[collection][idx]
+	switch action {
+	case MailPasswordReset:
+		if errs := checkPassword(data); errs != nil {
+			writeError(w, http.StatusBadRequest, "An error occurred while validating the submitted data.", errs)
+			return
+		}
+		record["password"] = data["password"]
+	case MailVerification:
+		record["verified"] = true
+	}
+	record["updated"] = now()
+	delete(s.mailTokens, token)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,487 @@
+// Package pbtest provides an in-memory fake PocketBase server to test code
+// relying on pbclient without a running instance.
+package pbtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/josuebrunel/sportdropin/pkg/pbclient"
+)
+
+const (
+	DefaultPerPage = 30
+	MaxPerPage     = 500
+)
+
+// Record is a record as stored by the fake server.
+type Record = map[string]any
+
+// Request is a request received by the fake server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+type failure struct {
+	status  int
+	message string
+}
+
+// Server is a fake PocketBase implementing the records CRUD, the password
+// and OAuth2 auth, the auth refresh and the password reset and verification
+// endpoints against in-memory collections.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	seq         int
+	collections map[string][]Record
+	relations   map[string]string
+	tokens      map[string]string
+	protected   map[string]bool
+	requests    []Request
+	failures    []failure
+	providers   map[string]Record
+	states      map[string]oauth2Code
+	codes       map[string]oauth2Code
+	mails       []Mail
+	mailTokens  map[string]mailToken
+}
+
+func NewServer() *Server {
+	s := &Server{
+		collections: map[string][]Record{},
+		relations:   map[string]string{},
+		tokens:      map[string]string{},
+		protected:   map[string]bool{},
+		providers:   map[string]Record{},
+		states:      map[string]oauth2Code{},
+		codes:       map[string]oauth2Code{},
+		mailTokens:  map[string]mailToken{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a pbclient.Client pointing at the fake server.
+func (s *Server) Client() pbclient.Client {
+	c := pbclient.New(s.URL)
+	c.Retry.MaxRetries = 0
+	return c
+}
+
+// Seed adds records to collection and returns them as stored, with their
+// id and system fields filled in.
+func (s *Server) Seed(collection string, records ...Record) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seeded := make([]Record, 0, len(records))
+	for _, r := range records {
+		seeded = append(seeded, copyRecord(s.insert(collection, r)))
+	}
+	return seeded
+}
+
+// Records returns a copy of the records stored in collection.
+func (s *Server) Records(collection string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rr := make([]Record, 0, len(s.collections[collection]))
+	for _, r := range s.collections[collection] {
+		rr = append(rr, copyRecord(r))
+	}
+	return rr
+}
+
+// Relation declares that field of collection points to target. By default
+// a relation field targets the collection named after the field with or
+// without a trailing "s" (i.e. sport -> sports).
+func (s *Server) Relation(collection, field, target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.relations[collection+"."+field] = target
+}
+
+// RequireAuth makes the records endpoints of collections reject requests
+// without a valid token.
+func (s *Server) RequireAuth(collections ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range collections {
+		s.protected[c] = true
+	}
+}
+
+// ExpireTokens invalidates every issued token.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]string{}
+}
+
+// FailNext makes the next request fail with status and message.
+func (s *Server) FailNext(status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{status: status, message: message})
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request{}, s.requests...)
+}
+
+// RequestsTo returns the requests received for method and path.
+func (s *Server) RequestsTo(method, path string) []Request {
+	rr := []Request{}
+	for _, r := range s.Requests() {
+		if r.Method == method && r.Path == path {
+			rr = append(rr, r)
+		}
+	}
+	return rr
+}
+
+// LastRequest returns the last request received.
+func (s *Server) LastRequest() Request {
+	rr := s.Requests()
+	if len(rr) == 0 {
+		return Request{}
+	}
+	return rr[len(rr)-1]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	if len(s.failures) > 0 {
+		f := s.failures[0]
+		s.failures = s.failures[1:]
+		writeError(w, f.status, f.message, nil)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 3 && parts[0] == "oauth2" && parts[2] == "authorize" {
+		s.authorize(w, r, parts[1])
+		return
+	}
+	if len(parts) < 4 || parts[0] != "api" || parts[1] != "collections" {
+		writeError(w, http.StatusNotFound, "The requested resource wasn't found.", nil)
+		return
+	}
+	collection := parts[2]
+	switch {
+	case len(parts) == 4 && parts[3] == "auth-with-password" && r.Method == http.MethodPost:
+		s.authWithPassword(w, collection, body)
+	case len(parts) == 4 && parts[3] == "auth-refresh" && r.Method == http.MethodPost:
+		s.authRefresh(w, r, collection)
+	case len(parts) == 4 && parts[3] == "auth-methods" && r.Method == http.MethodGet:
+		s.authMethods(w)
+	case len(parts) == 4 && parts[3] == "auth-with-oauth2" && r.Method == http.MethodPost:
+		s.authWithOAuth2(w, collection, body)
+	case len(parts) == 4 && parts[3] == "request-password-reset" && r.Method == http.MethodPost:
+		s.requestMail(w, collection, MailPasswordReset, body)
+	case len(parts) == 4 && parts[3] == "confirm-password-reset" && r.Method == http.MethodPost:
+		s.confirmMail(w, collection, MailPasswordReset, body)
+	case len(parts) == 4 && parts[3] == "request-verification" && r.Method == http.MethodPost:
+		s.requestMail(w, collection, MailVerification, body)
+	case len(parts) == 4 && parts[3] == "confirm-verification" && r.Method == http.MethodPost:
+		s.confirmMail(w, collection, MailVerification, body)
+	case parts[3] == "records":
+		if s.protected[collection] && s.authID(r) == "" {
+			writeError(w, http.StatusUnauthorized, "The request requires valid record authorization token to be set.", nil)
+			return
+		}
+		s.records(w, r, collection, parts[4:], body)
+	default:
+		writeError(w, http.StatusNotFound, "The requested resource wasn't found.", nil)
+	}
+}
+
+func (s *Server) records(w http.ResponseWriter, r *http.Request, collection string, rest []string, body []byte) {
+	q := r.URL.Query()
+	if len(rest) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			s.list(w, collection, q)
+		case http.MethodPost:
+			data, err := decodeBody(r, body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "Failed to load the submitted data due to invalid formatting.", nil)
+				return
+			}
+			if collection == "users" {
+				if errs := checkPassword(data); errs != nil {
+					writeError(w, http.StatusBadRequest, "Failed to create record.", errs)
+					return
+				}
+			}
+			writeJSON(w, http.StatusOK, s.view(collection, s.insert(collection, data), q))
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", nil)
+		}
+		return
+	}
+	idx := s.index(collection, rest[0])
+	if idx < 0 {
+		writeError(w, http.StatusNotFound, "The requested resource wasn't found.", nil)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.view(collection, s.collections[collection][idx], q))
+	case http.MethodPatch:
+		data, err := decodeBody(r, body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to load the submitted data due to invalid formatting.", nil)
+			return
+		}
+		if collection == "users" {
+			if pwd, _ := data["password"].(string); pwd == "" {
+				delete(data, "password")
+				delete(data, "passwordConfirm")
+			} else if errs := checkPassword(data); errs != nil {
+				writeError(w, http.StatusBadRequest, "Failed to update record.", errs)
+				return
+			}
+		}
+		record := s.collections[collection][idx]
+		for k, v := range data {
+			if k == "id" || k == "created" || k == "collectionId" || k == "collectionName" {
+				continue
+			}
+			record[k] = v
+		}
+		record["updated"] = now()
+		writeJSON(w, http.StatusOK, s.view(collection, record, q))
+	case http.MethodDelete:
+		rr := s.collections[collection]
+		s.collections[collection] = append(rr[:idx:idx], rr[idx+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed", nil)
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, collection string, q url.Values) {
+	items := []Record{}
+	for _, r := range s.collections[collection] {
+		ok, err := s.match(collection, r, q.Get("filter"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Something went wrong while processing your request. Invalid filter parameters.", nil)
+			return
+		}
+		if ok {
+			items = append(items, r)
+		}
+	}
+	s.sort(collection, items, q.Get("sort"))
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	page = max(page, 1)
+	perPage, _ := strconv.Atoi(q.Get("perPage"))
+	if perPage <= 0 {
+		perPage = DefaultPerPage
+	}
+	perPage = min(perPage, MaxPerPage)
+	var (
+		total      = len(items)
+		totalPages = (total + perPage - 1) / perPage
+		start      = min((page-1)*perPage, total)
+		end        = min(start+perPage, total)
+		views      = make([]Record, 0, end-start)
+	)
+	for _, r := range items[start:end] {
+		views = append(views, s.view(collection, r, q))
+	}
+	if q.Get("skipTotal") == "true" || q.Get("skipTotal") == "1" {
+		total, totalPages = -1, -1
+	}
+	writeJSON(w, http.StatusOK, pbclient.Records[Record]{
+		Page:       page,
+		PerPage:    perPage,
+		TotalItems: total,
+		TotalPages: totalPages,
+		Items:      views,
+	})
+}
+
+func (s *Server) authWithPassword(w http.ResponseWriter, collection string, body []byte) {
+	var req pbclient.RequestAuth
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to authenticate.", nil)
+		return
+	}
+	for _, r := range s.collections[collection] {
+		if r["username"] != req.Identity && r["email"] != req.Identity {
+			continue
+		}
+		if r["password"] != req.Password {
+			break
+		}
+		s.writeAuth(w, collection, r)
+		return
+	}
+	writeError(w, http.StatusBadRequest, "Failed to authenticate.", nil)
+}
+
+func (s *Server) authRefresh(w http.ResponseWriter, r *http.Request, collection string) {
+	idx := s.index(collection, s.authID(r))
+	if idx < 0 {
+		writeError(w, http.StatusUnauthorized, "The request requires valid record authorization token to be set.", nil)
+		return
+	}
+	delete(s.tokens, r.Header.Get("Authorization"))
+	s.writeAuth(w, collection, s.collections[collection][idx])
+}
+
+func (s *Server) writeAuth(w http.ResponseWriter, collection string, r Record) {
+	s.seq++
+	token := fmt.Sprintf("pbtest-%s-%d", r["id"], s.seq)
+	s.tokens[token] = r["id"].(string)
+	writeJSON(w, http.StatusOK, map[string]any{"token": token, "record": s.view(collection, r, nil)})
+}
+
+func (s *Server) authID(r *http.Request) string {
+	return s.tokens[r.Header.Get("Authorization")]
+}
+
+func (s *Server) insert(collection string, data Record) Record {
+	r := copyRecord(data)
+	if id, _ := r["id"].(string); id == "" {
+		s.seq++
+		r["id"] = fmt.Sprintf("%015d", s.seq)
+	}
+	if _, ok := r["created"]; !ok {
+		r["created"] = now()
+	}
+	if _, ok := r["updated"]; !ok {
+		r["updated"] = r["created"]
+	}
+	r["collectionId"] = collection
+	r["collectionName"] = collection
+	delete(r, "passwordConfirm")
+	s.collections[collection] = append(s.collections[collection], r)
+	return r
+}
+
+func (s *Server) index(collection, id string) int {
+	for i, r := range s.collections[collection] {
+		if r["id"] == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// view returns the public representation of r with the requested expand.
+func (s *Server) view(collection string, r Record, q url.Values) Record {
+	v := copyRecord(r)
+	delete(v, "password")
+	if q != nil && q.Get("expand") != "" {
+		if expand := s.expand(collection, r, q.Get("expand")); len(expand) > 0 {
+			v["expand"] = expand
+		}
+	}
+	return v
+}
+
+func checkPassword(data Record) map[string]pbclient.ResponseFieldError {
+	pwd, _ := data["password"].(string)
+	if pwd == "" {
+		return map[string]pbclient.ResponseFieldError{
+			"password": {Code: "validation_required", Message: "Missing required value."},
+		}
+	}
+	if confirm, ok := data["passwordConfirm"]; ok && confirm != pwd {
+		return map[string]pbclient.ResponseFieldError{
+			"passwordConfirm": {Code: "validation_values_mismatch", Message: "Values don't match."},
+		}
+	}
+	return nil
+}
+
+func decodeBody(r *http.Request, body []byte) (Record, error) {
+	data := Record{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		// serve already read the body to record the request
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		for k, v := range r.MultipartForm.Value {
+			if len(v) == 1 {
+				data[k] = v[0]
+			} else {
+				data[k] = v
+			}
+		}
+		for k, files := range r.MultipartForm.File {
+			names := make([]string, 0, len(files))
+			for _, f := range files {
+				names = append(names, f.Filename)
+			}
+			if len(names) == 1 {
+				data[k] = names[0]
+			} else {
+				data[k] = names
+			}
+		}
+		return data, nil
+	}
+	if len(body) == 0 {
+		return data, nil
+	}
+	return data, json.Unmarshal(body, &data)
+}
+
+func copyRecord(r Record) Record {
+	c := make(Record, len(r))
+	for k, v := range r {
+		c[k] = v
+	}
+	return c
+}
+
+func now() string {
+	return time.Now().UTC().Format(pbclient.DateTimeLayout)
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, message string, data map[string]pbclient.ResponseFieldError) {
+	if data == nil {
+		data = map[string]pbclient.ResponseFieldError{}
+	}
+	writeJSON(w, status, pbclient.ResponseError{Code: status, Message: message, Data: data})
+}
@@ -0,0 +1,204 @@
+package pbtest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/josuebrunel/sportdropin/pkg/pbclient"
+)
+
+type sport struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type group struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Sport  string `json:"sport"`
+	Logo   string `json:"logo"`
+	Expand struct {
+		Sport sport `json:"sport"`
+	} `json:"expand"`
+}
+
+func TestRecordsCRUD(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	api := s.Client()
+	sports := s.Seed("sports", Record{"name": "soccer"})
+	groups := pbclient.Collection[group](&api, "groups")
+
+	created, err := groups.Create(map[string]any{"name": "fc", "sport": sports[0]["id"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Name != "fc" {
+		t.Fatalf("unexpected created record %+v", created)
+	}
+	updated, err := groups.Update(created.ID, map[string]any{"name": "fc united"})
+	if err != nil || updated.Name != "fc united" {
+		t.Fatalf("update: %+v, %v", updated, err)
+	}
+	got, err := groups.Get(created.ID, pbclient.QExpand{"sport"})
+	if err != nil || got.Expand.Sport.Name != "soccer" {
+		t.Fatalf("get: %+v, %v", got, err)
+	}
+	if err := groups.Delete(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := groups.Get(created.ID); err == nil {
+		t.Fatal("deleted record still found")
+	}
+}
+
+func TestRecordsMultipart(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	api := s.Client()
+	groups := pbclient.Collection[group](&api, "groups")
+
+	data, err := pbclient.NewQMultipart(map[string]any{"name": "fc"}, pbclient.NewQFile("logo", "logo.png", strings.NewReader("png")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := groups.Create(data)
+	if err != nil {
+		t.Fatalf("multipart create: %v", err)
+	}
+	if created.Name != "fc" || created.Logo != "logo.png" {
+		t.Fatalf("unexpected created record %+v", created)
+	}
+
+	data, _ = pbclient.NewQMultipart(map[string]any{"name": "fc united"}, pbclient.NewQFile("logo", "new.png", strings.NewReader("png")))
+	updated, err := groups.Update(created.ID, data)
+	if err != nil {
+		t.Fatalf("multipart update: %v", err)
+	}
+	if updated.Name != "fc united" || updated.Logo != "new.png" {
+		t.Fatalf("unexpected updated record %+v", updated)
+	}
+	if body := s.LastRequest().Body; !strings.Contains(string(body), "new.png") {
+		t.Errorf("recorded body %q misses the file", body)
+	}
+}
+
+func TestRecordsList(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	api := s.Client()
+	s.Seed("groups", Record{"name": "b"}, Record{"name": "a"}, Record{"name": "c"}, Record{"name": "it's"})
+	groups := pbclient.Collection[group](&api, "groups")
+
+	tests := []struct {
+		name string
+		qs   []pbclient.IQ
+		want []string
+	}{
+		{"all sorted", []pbclient.IQ{pbclient.QSort{"name"}}, []string{"a", "b", "c", "it's"}},
+		{"desc", []pbclient.IQ{pbclient.QSort{"-name"}}, []string{"it's", "c", "b", "a"}},
+		{"filter", []pbclient.IQ{pbclient.In("name", "a", "c"), pbclient.QSort{"name"}}, []string{"a", "c"}},
+		{"quoted filter", []pbclient.IQ{pbclient.Eq("name", "it's")}, []string{"it's"}},
+		{"page", []pbclient.IQ{pbclient.QSort{"name"}, pbclient.QPage{Page: 2, PerPage: 3}}, []string{"it's"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := groups.List(tt.qs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, g := range res.Items {
+				names = append(names, g.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Seed("users", Record{"username": "joe", "email": "joe@example.com", "password": "secret123"})
+	s.RequireAuth("groups")
+	api := s.Client()
+
+	if _, err := pbclient.Collection[group](&api, "groups").List(); err == nil {
+		t.Fatal("anonymous list of a protected collection succeeded")
+	}
+	if _, err := api.UserAuthCtx(context.Background(), "joe", "wrong"); err == nil {
+		t.Fatal("auth with a wrong password succeeded")
+	}
+	if _, err := api.UserAuthCtx(context.Background(), "joe", "secret123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pbclient.Collection[group](&api, "groups").List(); err != nil {
+		t.Fatalf("authenticated list: %v", err)
+	}
+	if h := s.LastRequest().Header.Get("Authorization"); h == "" {
+		t.Error("token not sent")
+	}
+}
+
+func TestPasswordResetAndVerification(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Seed("users", Record{"username": "joe", "email": "joe@example.com", "password": "secret123"})
+	api := s.Client()
+
+	if _, err := api.RequestPasswordReset("nobody@example.com"); err != nil {
+		t.Fatalf("reset of an unknown email: %v", err)
+	}
+	if _, err := api.RequestPasswordReset("joe@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.RequestVerification("joe@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	mails := s.Mails()
+	if len(mails) != 2 || mails[0].To != "joe@example.com" || mails[0].Action != MailPasswordReset || mails[1].Action != MailVerification {
+		t.Fatalf("unexpected mails %+v", mails)
+	}
+	reset, verify := mails[0].Token, mails[1].Token
+
+	if _, err := api.ConfirmPasswordReset(pbclient.RequestConfirmPasswordReset{Token: verify, Password: "new", PasswordConfirm: "new"}); err == nil {
+		t.Fatal("reset with a verification token succeeded")
+	}
+	if _, err := api.ConfirmPasswordReset(pbclient.RequestConfirmPasswordReset{Token: reset, Password: "newsecret", PasswordConfirm: "newsecret"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.ConfirmPasswordReset(pbclient.RequestConfirmPasswordReset{Token: reset, Password: "again", PasswordConfirm: "again"}); err == nil {
+		t.Fatal("spent token accepted")
+	}
+	if _, err := api.UserAuthCtx(context.Background(), "joe", "newsecret"); err != nil {
+		t.Fatalf("auth with the new password: %v", err)
+	}
+	if _, err := api.ConfirmVerification(verify); err != nil {
+		t.Fatal(err)
+	}
+	if s.Records("users")[0]["verified"] != true {
+		t.Error("user not verified")
+	}
+	if _, err := api.RequestVerification("joe@example.com"); err != nil || len(s.Mails()) != 2 {
+		t.Errorf("verification of a verified email: %v, got mails %+v", err, s.Mails())
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	api := s.Client()
+	s.FailNext(http.StatusBadRequest, "boom")
+	_, err := pbclient.Collection[group](&api, "groups").List()
+	apiErr, ok := pbclient.AsAPIError(err)
+	if !ok || apiErr.Code != http.StatusBadRequest || apiErr.Message != "boom" {
+		t.Fatalf("got %v, want the injected failure", err)
+	}
+	if _, err := pbclient.Collection[group](&api, "groups").List(); err != nil {
+		t.Fatalf("failure not consumed: %v", err)
+	}
+}
@@ -10,14 +10,15 @@ import (
 )
 
 const (
-	QTypeData    = "data"
-	QTypeExpand  = "expand"
-	QTypeFields  = "fields"
-	QTypeFilters = "filters"
-	QTypeHeaders = "headers"
-	QTypePage    = "page"
-	QTypeParams  = "params"
-	QTypeSort    = "sort"
+	QTypeData      = "data"
+	QTypeExpand    = "expand"
+	QTypeFields    = "fields"
+	QTypeFilters   = "filter"
+	QTypeHeaders   = "headers"
+	QTypeMultipart = "multipart"
+	QTypePage      = "page"
+	QTypeParams    = "params"
+	QTypeSort      = "sort"
 )
 
 type IQ interface {
@@ -0,0 +1,191 @@
+package pbclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+)
+
+const (
+	EndpointRealtime = "/api/realtime"
+
+	RealtimeConnect = "PB_CONNECT"
+
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+var (
+	RealtimeMinReconnectDelay = time.Second
+	RealtimeMaxReconnectDelay = 30 * time.Second
+
+	ErrRealtimeClosed = errors.New("realtime stream closed")
+)
+
+// RealtimeEvent is a record change pushed by PocketBase for a subscribed
+// topic such as "memberstats/*" or "memberstats/RECORD_ID".
+type RealtimeEvent[T any] struct {
+	Topic  string `json:"-"`
+	Action string `json:"action"`
+	Record T      `json:"record"`
+}
+
+type sseMessage struct {
+	id    string
+	event string
+	data  strings.Builder
+}
+
+type realtime[T any] struct {
+	client *Client
+	topics []string
+	events chan RealtimeEvent[T]
+	lastID string
+}
+
+// Subscribe opens the PocketBase realtime stream and subscribes to topics.
+// Events are delivered on the returned channel which is closed once ctx is
+// done. Dropped connections are re-established and topics resubmitted.
+func Subscribe[T any](ctx context.Context, c *Client, topics ...string) <-chan RealtimeEvent[T] {
+	rt := &realtime[T]{client: c, topics: topics, events: make(chan RealtimeEvent[T])}
+	go rt.run(ctx)
+	return rt.events
+}
+
+func (rt *realtime[T]) run(ctx context.Context) {
+	defer close(rt.events)
+	delay := RealtimeMinReconnectDelay
+	for {
+		connected, err := rt.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = RealtimeMinReconnectDelay
+		}
+		xlog.Error("realtime connection lost, reconnecting", "topics", rt.topics, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, RealtimeMaxReconnectDelay)
+	}
+}
+
+func (rt *realtime[T]) listen(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rt.client.buildUrl(EndpointRealtime), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if token := rt.client.Auth.Token(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	if rt.lastID != "" {
+		req.Header.Set("Last-Event-ID", rt.lastID)
+	}
+	httpClient := http.DefaultClient
+	if rt.client.Client != nil && rt.client.Client.HttpClient != nil {
+		httpClient = rt.client.Client.HttpClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return false, newAPIError(resp)
+	}
+
+	var (
+		connected bool
+		msg       = &sseMessage{}
+		scanner   = bufio.NewScanner(resp.Body)
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			msg.parse(line)
+			continue
+		}
+		if msg.event == "" && msg.data.Len() == 0 {
+			continue
+		}
+		if msg.id != "" {
+			rt.lastID = msg.id
+		}
+		if msg.event == RealtimeConnect {
+			if err := rt.subscribe(ctx, msg.data.String()); err != nil {
+				return connected, err
+			}
+			connected = true
+		} else if err := rt.dispatch(ctx, msg); err != nil {
+			return connected, err
+		}
+		msg = &sseMessage{}
+	}
+	if err := scanner.Err(); err != nil {
+		return connected, err
+	}
+	return connected, ErrRealtimeClosed
+}
+
+func (m *sseMessage) parse(line string) {
+	if strings.HasPrefix(line, ":") {
+		return
+	}
+	name, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+	switch name {
+	case "id":
+		m.id = value
+	case "event":
+		m.event = value
+	case "data":
+		if m.data.Len() > 0 {
+			m.data.WriteString("\n")
+		}
+		m.data.WriteString(value)
+	}
+}
+
+func (rt *realtime[T]) subscribe(ctx context.Context, data string) error {
+	var connect struct {
+		ClientID string `json:"clientId"`
+	}
+	if err := json.Unmarshal([]byte(data), &connect); err != nil {
+		return err
+	}
+	xlog.Debug("realtime connected", "clientId", connect.ClientID, "topics", rt.topics)
+	resp, err := rt.client.RequestCtx(ctx, http.MethodPost, EndpointRealtime, NewQData(map[string]any{
+		"clientId":      connect.ClientID,
+		"subscriptions": rt.topics,
+	}))
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func (rt *realtime[T]) dispatch(ctx context.Context, msg *sseMessage) error {
+	event := RealtimeEvent[T]{Topic: msg.event}
+	if err := json.Unmarshal([]byte(msg.data.String()), &event); err != nil {
+		xlog.Error("failed to decode realtime event", "topic", msg.event, "error", err)
+		return nil
+	}
+	select {
+	case rt.events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
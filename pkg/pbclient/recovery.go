@@ -0,0 +1,88 @@
+package pbclient
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	EndpointRequestPasswordReset = "/api/collections/users/request-password-reset"
+	EndpointConfirmPasswordReset = "/api/collections/users/confirm-password-reset"
+	EndpointRequestVerification  = "/api/collections/users/request-verification"
+	EndpointConfirmVerification  = "/api/collections/users/confirm-verification"
+	EndpointRequestEmailChange   = "/api/collections/users/request-email-change"
+	EndpointConfirmEmailChange   = "/api/collections/users/confirm-email-change"
+)
+
+type RequestEmail struct {
+	Email string `json:"email" form:"email"`
+}
+
+type RequestNewEmail struct {
+	NewEmail string `json:"newEmail" form:"newEmail"`
+}
+
+type RequestConfirmPasswordReset struct {
+	Token           string `json:"token" form:"token"`
+	Password        string `json:"password" form:"password"`
+	PasswordConfirm string `json:"passwordConfirm" form:"passwordConfirm"`
+}
+
+type RequestConfirmVerification struct {
+	Token string `json:"token" form:"token"`
+}
+
+type RequestConfirmEmailChange struct {
+	Token    string `json:"token" form:"token"`
+	Password string `json:"password" form:"password"`
+}
+
+func (c *Client) RequestPasswordReset(email string) (*http.Response, error) {
+	return c.RequestPasswordResetCtx(context.Background(), email)
+}
+
+func (c *Client) RequestPasswordResetCtx(ctx context.Context, email string) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPost, EndpointRequestPasswordReset, NewQData(RequestEmail{Email: email}))
+}
+
+func (c *Client) ConfirmPasswordReset(req RequestConfirmPasswordReset) (*http.Response, error) {
+	return c.ConfirmPasswordResetCtx(context.Background(), req)
+}
+
+func (c *Client) ConfirmPasswordResetCtx(ctx context.Context, req RequestConfirmPasswordReset) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPost, EndpointConfirmPasswordReset, NewQData(req))
+}
+
+func (c *Client) RequestVerification(email string) (*http.Response, error) {
+	return c.RequestVerificationCtx(context.Background(), email)
+}
+
+func (c *Client) RequestVerificationCtx(ctx context.Context, email string) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPost, EndpointRequestVerification, NewQData(RequestEmail{Email: email}))
+}
+
+func (c *Client) ConfirmVerification(token string) (*http.Response, error) {
+	return c.ConfirmVerificationCtx(context.Background(), token)
+}
+
+func (c *Client) ConfirmVerificationCtx(ctx context.Context, token string) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPost, EndpointConfirmVerification, NewQData(RequestConfirmVerification{Token: token}))
+}
+
+// RequestEmailChange requires the client to be authenticated as the user
+// changing email.
+func (c *Client) RequestEmailChange(newEmail string) (*http.Response, error) {
+	return c.RequestEmailChangeCtx(context.Background(), newEmail)
+}
+
+func (c *Client) RequestEmailChangeCtx(ctx context.Context, newEmail string) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPost, EndpointRequestEmailChange, NewQData(RequestNewEmail{NewEmail: newEmail}))
+}
+
+func (c *Client) ConfirmEmailChange(req RequestConfirmEmailChange) (*http.Response, error) {
+	return c.ConfirmEmailChangeCtx(context.Background(), req)
+}
+
+func (c *Client) ConfirmEmailChangeCtx(ctx context.Context, req RequestConfirmEmailChange) (*http.Response, error) {
+	return c.RequestCtx(ctx, http.MethodPost, EndpointConfirmEmailChange, NewQData(req))
+}
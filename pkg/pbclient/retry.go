@@ -0,0 +1,68 @@
+package pbclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy tells the client when and how to retry a failed request.
+// Transport errors and 5xx responses are only retried for idempotent
+// methods while 429 responses are retried for any method.
+type RetryPolicy struct {
+	MaxRetries int
+	MinDelay   time.Duration
+	MaxDelay   time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	MinDelay:   200 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func (p RetryPolicy) retryable(method string, err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	apiErr, ok := AsAPIError(err)
+	if !ok {
+		return isIdempotent(method)
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// backoff returns a full jitter exponential delay for the given attempt.
+// A Retry-After header sent by the server takes precedence.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return min(time.Duration(secs)*time.Second, p.MaxDelay)
+		}
+	}
+	ceil := p.MinDelay << attempt
+	if ceil <= 0 || ceil > p.MaxDelay {
+		ceil = p.MaxDelay
+	}
+	if ceil <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceil)) + 1)
+}
@@ -5,10 +5,15 @@ type RequestAuth struct {
 	Password string `json:"password"`
 }
 
-type ResponseError struct {
-	Code    int    `json:"code"`
+type ResponseFieldError struct {
+	Code    string `json:"code"`
 	Message string `json:"message"`
-	Data    any    `json:"data"`
+}
+
+type ResponseError struct {
+	Code    int                           `json:"code"`
+	Message string                        `json:"message"`
+	Data    map[string]ResponseFieldError `json:"data"`
 }
 
 type UserRecord struct {
@@ -21,6 +26,8 @@ type UserRecord struct {
 	Email           string `json:"email"`
 	Verified        bool   `json:"verified"`
 	EmailVisibility bool   `json:"emailVisibility"`
+	Name            string `json:"name"`
+	Avatar          string `json:"avatar"`
 }
 
 type ResponseAuth struct {
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+)
+
+// BackRel describes the records of Collection pointing back to the listed
+// records through their Key relation field (i.e. the memberstats of a
+// member through memberstats.member). Key may be a single or a multiple
+// relation. Filters narrows the related records and As names the expand
+// key, Collection by default.
+type BackRel struct {
+	Collection string
+	Key        string
+	Filters    Filters
+	As         string
+}
+
+func (r BackRel) name() string {
+	if r.As != "" {
+		return r.As
+	}
+	return r.Collection
+}
+
+// expandBackRels resolves each of rels with a single query over all the
+// records and sets the related records in their expand. Records without
+// related records get an empty list.
+func (s Service) expandBackRels(ctx context.Context, records RecordSlice, rels ...BackRel) error {
+	if len(records) == 0 || len(rels) == 0 {
+		return nil
+	}
+	ids := make([]any, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.GetId())
+	}
+	expands := make([]map[string]any, len(records))
+	for i := range expands {
+		expands[i] = map[string]any{}
+	}
+	for _, rel := range rels {
+		related, err := s.findBackRel(ctx, rel, ids)
+		if err != nil {
+			return err
+		}
+		byKey := map[string]RecordSlice{}
+		for _, r := range related {
+			for _, id := range r.GetStringSlice(rel.Key) {
+				byKey[id] = append(byKey[id], r)
+			}
+		}
+		for i, r := range records {
+			rr := byKey[r.GetId()]
+			if rr == nil {
+				rr = RecordSlice{}
+			}
+			expands[i][rel.name()] = rr
+		}
+	}
+	for i, r := range records {
+		r.MergeExpand(expands[i])
+	}
+	return nil
+}
+
+func (s Service) findBackRel(ctx context.Context, rel BackRel, ids []any) (RecordSlice, error) {
+	related := NewService(rel.Collection, "", s.db)
+	c := related.GetCollection()
+	if !hasField(c, rel.Key) {
+		return nil, fmt.Errorf("unknown relation field %q", rel.Key)
+	}
+	q := s.db.RecordQuery(rel.Collection).WithContext(ctx).AndWhere(relationExp(c, rel.Key, ids))
+	where, err := related.where(rel.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if where != nil {
+		q.AndWhere(where)
+	}
+	if related.SoftDelete() {
+		q.AndWhere(trashExp(false))
+	}
+	records := RecordSlice{}
+	err = q.All(&records)
+	return records, err
+}
+
+// relationExp matches the records of c whose relation field key holds one
+// of ids. A multiple relation is stored as a json array and is looked into
+// with json_each.
+func relationExp(c *models.Collection, key string, ids []any) dbx.Expression {
+	var opts *schema.RelationOptions
+	if f := c.Schema.GetFieldByName(key); f != nil {
+		opts, _ = f.Options.(*schema.RelationOptions)
+	}
+	if opts == nil || !opts.IsMultiple() {
+		return dbx.In(key, ids...)
+	}
+	params := dbx.Params{}
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		p := fmt.Sprintf("rel%d", i)
+		params[p] = id
+		placeholders[i] = "{:" + p + "}"
+	}
+	col := "[[" + c.Name + "." + key + "]]"
+	return dbx.NewExp(fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM json_each(CASE WHEN json_valid(%s) THEN %s ELSE json_array(%s) END) [[rel]] WHERE [[rel.value]] IN (%s))",
+		col, col, col, strings.Join(placeholders, ", "),
+	), params)
+}
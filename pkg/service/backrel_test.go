@@ -0,0 +1,125 @@
+//go:build !goexperiment.jsonv2
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+func expanded(r Record, name string) []string {
+	names := []string{}
+	for _, rel := range r.ExpandedAll(name) {
+		names = append(names, rel.GetString("name"))
+	}
+	return names
+}
+
+func TestBackRel(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	teams, _ := f.dao.FindCollectionByNameOrId("teams")
+	newTestCollection(t, f.dao, "tags", textField("name"), relationField("teams", teams, 5))
+	tags := NewService("tags", "tagid", f.dao)
+
+	lions := create(t, f.teams, Request{"name": "Lions"})
+	tigers := create(t, f.teams, Request{"name": "Tigers"})
+	create(t, f.teams, Request{"name": "Bears"})
+	create(t, f.players, Request{"name": "ann", "team": lions.Id})
+	create(t, f.players, Request{"name": "bob", "team": lions.Id})
+	create(t, f.players, Request{"name": "cat", "team": tigers.Id})
+	gone := create(t, f.players, Request{"name": "dan", "team": tigers.Id})
+	f.players.Delete(ctx, gone.Id)
+	create(t, tags, Request{"name": "north", "teams": []string{lions.Id, tigers.Id}})
+	create(t, tags, Request{"name": "pro", "teams": []string{tigers.Id}})
+
+	vd, err := f.teams.ListWithBackRel(ctx, NewQuery(nil, "name"),
+		BackRel{Collection: "players", Key: "team"},
+		BackRel{Collection: "players", Key: "team", Filters: Filters{"name": "bob"}, As: "bobs"},
+		BackRel{Collection: "tags", Key: "teams"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string][]string{
+		"Bears":  {"players": {}, "bobs": {}, "tags": {}},
+		"Lions":  {"players": {"ann", "bob"}, "bobs": {"bob"}, "tags": {"north"}},
+		"Tigers": {"players": {"cat"}, "bobs": {}, "tags": {"north", "pro"}},
+	}
+	for _, team := range vd.V().Items {
+		for rel, names := range want[team.GetString("name")] {
+			got := expanded(team, rel)
+			if fmt.Sprint(got) != fmt.Sprint(names) {
+				t.Errorf("%s %s: got %v, want %v", team.GetString("name"), rel, got, names)
+			}
+		}
+	}
+
+	one, err := f.teams.GetByIDWithBackRel(ctx, tigers.Id, BackRel{Collection: "tags", Key: "teams"})
+	if err != nil || fmt.Sprint(expanded(one.V(), "tags")) != "[north pro]" {
+		t.Fatalf("got %v, %v", expanded(one.V(), "tags"), err)
+	}
+	if _, err := f.teams.ListWithBackRel(ctx, NewQuery(nil), BackRel{Collection: "players", Key: "nope"}); err == nil {
+		t.Error("unknown relation field accepted")
+	}
+}
+
+// seedLeague adds teams with players each.
+func seedLeague(b *testing.B, f fixture, teams, players int) {
+	b.Helper()
+	err := f.dao.RunInTransaction(func(tx *daos.Dao) error {
+		ts, ps := f.teams.WithDao(tx), f.players.WithDao(tx)
+		for i := 0; i < teams; i++ {
+			team, err := ts.create(context.Background(), Request{"name": fmt.Sprintf("team%d", i)})
+			if err != nil {
+				return err
+			}
+			for j := 0; j < players; j++ {
+				if _, err := ps.create(context.Background(), Request{"name": fmt.Sprintf("player%d", j), "team": team.Id}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+}
+
+// BenchmarkBackRel lists a page of 60 teams of 20 players, expanding the
+// players with a query per team as ListWithBackRel used to, then batched.
+// Run it with LOG_LEVEL=8: logging the listed records otherwise dominates.
+func BenchmarkBackRel(b *testing.B) {
+	f := newFixture(b)
+	seedLeague(b, f, 60, 20)
+	ctx := context.Background()
+	q := NewQuery(nil, "name").WithPage(1, 60)
+
+	b.Run("per record", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			page, err := f.teams.list(ctx, q)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, team := range page.Items {
+				players, err := f.dao.FindRecordsByExpr("players", dbx.HashExp{"team": team.Id}, trashExp(false))
+				if err != nil {
+					b.Fatal(err)
+				}
+				team.MergeExpand(map[string]any{"players": players})
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := f.teams.ListWithBackRel(ctx, q, BackRel{Collection: "players", Key: "team"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
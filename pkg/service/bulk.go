@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	"github.com/pocketbase/pocketbase/daos"
+)
+
+// BulkMode tells how a bulk operation deals with failing records.
+type BulkMode int
+
+const (
+	// BulkAtomic saves every record or none of them.
+	BulkAtomic BulkMode = iota
+	// BulkBestEffort saves the records that can be saved and reports the
+	// failing ones.
+	BulkBestEffort
+)
+
+var ErrBulk = errors.New("bulk operation failed")
+
+// WithDao returns a copy of the service running its queries against db,
+// i.e. a transaction.
+func (s Service) WithDao(db *daos.Dao) Service {
+	s.db = db
+	return s
+}
+
+// bulk runs op for each request in a single transaction, each request in
+// its own savepoint so the writes of a failing one, its hooks included, are
+// undone. Failing requests are reported in the returned map under their
+// index, "error" holding a summary. In atomic mode the transaction is
+// rolled back as soon as a request fails, after every request has been
+// checked.
+func (s Service) bulk(ctx context.Context, reqs Requests, mode BulkMode, op func(Service, context.Context, Request) (Record, error)) (RecordSlice, errorsmap.EMap, error) {
+	em := errorsmap.New()
+	records := RecordSlice{}
+	err := s.db.RunInTransaction(func(tx *daos.Dao) error {
+		txs := s.WithDao(tx)
+		for i, r := range reqs {
+			var record Record
+			err := savepoint(tx, func() (err error) {
+				record, err = op(txs, ctx, r)
+				return err
+			})
+			if err != nil {
+				em[strconv.Itoa(i)] = err
+				continue
+			}
+			records = append(records, record)
+		}
+		if failed := len(reqs) - len(records); failed > 0 {
+			err := fmt.Errorf("%w: %d of %d records failed", ErrBulk, failed, len(reqs))
+			if mode == BulkAtomic {
+				return err
+			}
+			em["error"] = err
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrBulk) {
+			err = fmt.Errorf("%w: %w", ErrBulk, err)
+		}
+		em["error"] = err
+		return RecordSlice{}, em, err
+	}
+	return records, em, em["error"]
+}
+
+// savepoint runs fn in a savepoint of the transaction tx and rolls back to
+// it when fn fails.
+func savepoint(tx *daos.Dao, fn func() error) error {
+	if _, err := tx.DB().NewQuery("SAVEPOINT bulk_row").Execute(); err != nil {
+		return err
+	}
+	err := fn()
+	if err != nil {
+		if _, rerr := tx.DB().NewQuery("ROLLBACK TO bulk_row").Execute(); rerr != nil {
+			return errors.Join(err, rerr)
+		}
+	}
+	if _, rerr := tx.DB().NewQuery("RELEASE bulk_row").Execute(); rerr != nil {
+		return errors.Join(err, rerr)
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+//go:build !goexperiment.jsonv2
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBulkCreate(t *testing.T) {
+	reqs := Requests{{"name": "Lions"}, {"city": "Paris"}, {"name": "Tigers"}}
+	tests := []struct {
+		name      string
+		mode      BulkMode
+		created   int
+		stored    int
+		wantError bool
+	}{
+		{"atomic", BulkAtomic, 0, 0, true},
+		{"best effort", BulkBestEffort, 2, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			vd, err := f.teams.BulkCreate(context.Background(), reqs, tt.mode)
+			if !errors.Is(err, ErrBulk) {
+				t.Fatalf("got %v, want ErrBulk", err)
+			}
+			if len(vd.V()) != tt.created {
+				t.Errorf("got %d records, want %d", len(vd.V()), tt.created)
+			}
+			if n := count(t, f.dao, "teams"); n != tt.stored {
+				t.Errorf("%d teams stored, want %d", n, tt.stored)
+			}
+			if vd.Errors["1"] == nil || vd.Errors["0"] != nil || vd.Errors["2"] != nil {
+				t.Errorf("got errors %v, want the second request only", vd.Errors)
+			}
+		})
+	}
+}
+
+func TestBulkCreateValid(t *testing.T) {
+	for _, mode := range []BulkMode{BulkAtomic, BulkBestEffort} {
+		f := newFixture(t)
+		vd, err := f.teams.BulkCreate(context.Background(), Requests{{"name": "Lions"}, {"name": "Tigers"}}, mode)
+		if err != nil || len(vd.V()) != 2 || vd.Errors["error"] != nil {
+			t.Fatalf("mode %d: got %d records, %v", mode, len(vd.V()), err)
+		}
+		if n := count(t, f.dao, "teams"); n != 2 {
+			t.Fatalf("mode %d: %d teams stored, want 2", mode, n)
+		}
+	}
+}
+
+func TestBulkUpsert(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	team := create(t, f.teams, Request{"name": "Lions"})
+	time.Sleep(time.Millisecond)
+
+	vd, err := f.teams.BulkUpsert(ctx, Requests{
+		{"id": team.Id, "name": "Tigers", VersionField: team.GetString(VersionField)},
+		{"name": "Bears"},
+	}, BulkAtomic)
+	if err != nil {
+		t.Fatal(err, vd.Errors)
+	}
+	stored, _ := f.dao.FindRecordById("teams", team.Id)
+	if stored.GetString("name") != "Tigers" {
+		t.Errorf("name is %q, want it updated", stored.GetString("name"))
+	}
+	if n := count(t, f.dao, "teams"); n != 2 {
+		t.Errorf("%d teams stored, want 2", n)
+	}
+
+	// the version sent is now stale: the whole batch is rejected
+	vd, err = f.teams.BulkUpsert(ctx, Requests{
+		{"name": "Wolves"},
+		{"id": team.Id, "name": "Sharks", VersionField: team.GetString(VersionField)},
+	}, BulkAtomic)
+	if !errors.Is(err, ErrBulk) || !errors.Is(vd.Errors["1"], ErrConflict) {
+		t.Fatalf("got %v, %v, want a conflict on the second request", err, vd.Errors)
+	}
+	if n := count(t, f.dao, "teams"); n != 2 {
+		t.Errorf("%d teams stored, want the batch rolled back", n)
+	}
+}
+
+func TestBulkBestEffortRollsBackFailingRows(t *testing.T) {
+	errVeto := errors.New("veto")
+	f := newFixture(t)
+	// each team gets a captain, written in the transaction of the team
+	f.teams.OnBeforeCreate().Add(func(e *RecordEvent) error {
+		_, err := f.players.WithDao(e.Dao).Create(e.Context, Request{"name": "captain of " + e.New.GetString("name")})
+		return err
+	})
+	f.teams.OnAfterCreate().Add(func(e *RecordEvent) error {
+		if e.New.GetString("city") == "nowhere" {
+			return errVeto
+		}
+		return nil
+	})
+	vd, err := f.teams.BulkCreate(context.Background(), Requests{
+		{"name": "Lions"},
+		{"name": "Ghosts", "city": "nowhere"},
+		{"city": "Paris"},
+		{"name": "Tigers"},
+	}, BulkBestEffort)
+	if !errors.Is(err, ErrBulk) || !errors.Is(vd.Errors["1"], errVeto) || vd.Errors["2"] == nil {
+		t.Fatalf("got %v, %v", err, vd.Errors)
+	}
+	names := func(collection string) []string {
+		records, _ := f.dao.FindRecordsByExpr(collection)
+		nn := []string{}
+		for _, r := range records {
+			nn = append(nn, r.GetString("name"))
+		}
+		return nn
+	}
+	if got := names("teams"); len(got) != 2 || got[0] != "Lions" || got[1] != "Tigers" {
+		t.Errorf("teams %v, want Lions and Tigers", got)
+	}
+	if got := names("players"); len(got) != 2 || got[0] != "captain of Lions" || got[1] != "captain of Tigers" {
+		t.Errorf("players %v, want the captains of Lions and Tigers only", got)
+	}
+}
+
+func TestBulkUpsertTrashed(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	team := create(t, f.teams, Request{"name": "Lions"})
+	f.teams.Delete(ctx, team.Id)
+
+	vd, err := f.teams.BulkUpsert(ctx, Requests{{"id": team.Id, "name": "Tigers"}}, BulkBestEffort)
+	if !errors.Is(err, ErrBulk) || vd.Errors["0"] == nil {
+		t.Fatalf("got %v, %v, want the trashed record rejected", err, vd.Errors)
+	}
+	stored, _ := f.dao.FindRecordById("teams", team.Id)
+	if stored.GetString("name") != "Lions" || stored.GetString(DeletedField) == "" {
+		t.Fatalf("trashed record changed: %v", stored)
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/dbx"
+)
+
+// OnDelete tells what happens to the dependent records of a deleted record.
+type OnDelete string
+
+const (
+	// Cascade deletes the dependent records along, through their service.
+	Cascade OnDelete = "cascade"
+	// Restrict fails the delete while there are dependent records.
+	Restrict OnDelete = "restrict"
+	// SetNull clears the relation field of the dependent records.
+	SetNull OnDelete = "setnull"
+)
+
+var ErrRestricted = errors.New("record is still in use")
+
+// RestrictError is returned by Delete when Restrict dependents are left.
+type RestrictError struct {
+	Label string
+	Count int
+}
+
+func (e RestrictError) Error() string {
+	return fmt.Sprintf("can't be deleted while %d %s refer to it", e.Count, e.Label)
+}
+
+func (e RestrictError) Unwrap() error { return ErrRestricted }
+
+// Dependent describes the records of Service pointing to the deleted record
+// through their Key relation field (i.e. the seasons of a group through
+// seasons.group). Label names them in messages, the collection name by
+// default.
+type Dependent struct {
+	Service  Service
+	Key      string
+	OnDelete OnDelete
+	Label    string
+}
+
+func (d Dependent) label() string {
+	if d.Label != "" {
+		return d.Label
+	}
+	return d.Service.Name
+}
+
+// applies reports whether the policy applies to a delete. Moving a record
+// to the trash only cascades to the dependents having a trash too, which
+// are restored along with it, and doesn't clear relations: the rest is
+// applied when the record is purged.
+func (d Dependent) applies(soft bool) bool {
+	return !soft || d.OnDelete == Restrict || (d.OnDelete == Cascade && d.Service.SoftDelete())
+}
+
+// WithDependents returns a copy of the service applying the OnDelete policy
+// of deps, in order, when deleting a record. The dependents are applied in
+// the transaction of the delete: a failure rolls every change back.
+func (s Service) WithDependents(deps ...Dependent) Service {
+	s.dependents = append(append([]Dependent{}, s.dependents...), deps...)
+	return s
+}
+
+// Affected is the number of records a delete applies an OnDelete policy to.
+type Affected struct {
+	Label    string
+	OnDelete OnDelete
+	Count    int
+}
+
+// Affected returns, per dependent collection, the number of live records a
+// delete of the record id would affect, cascades included. A record
+// reached through several relations is counted once.
+func (s Service) Affected(ctx context.Context, id string) ([]Affected, error) {
+	affected := []Affected{}
+	seen := map[string]bool{}
+	index := map[string]int{}
+	var walk func(s Service, id string) error
+	walk = func(s Service, id string) error {
+		soft := s.SoftDelete()
+		for _, dep := range s.dependents {
+			if !dep.applies(soft) {
+				continue
+			}
+			records, err := s.dependentsOf(ctx, dep, id, !soft)
+			if err != nil {
+				return err
+			}
+			for _, r := range records {
+				key := dep.Service.Name + "/" + r.Id
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				name := dep.label() + "/" + string(dep.OnDelete)
+				i, ok := index[name]
+				if !ok {
+					i, index[name] = len(affected), len(affected)
+					affected = append(affected, Affected{Label: dep.label(), OnDelete: dep.OnDelete})
+				}
+				affected[i].Count++
+				if dep.OnDelete != Cascade {
+					continue
+				}
+				if err := walk(dep.Service.WithDao(s.db), r.Id); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return affected, walk(s, id)
+}
+
+// dependentsOf returns the records of dep pointing to the record id, the
+// trashed ones included when trash is set.
+func (s Service) dependentsOf(ctx context.Context, dep Dependent, id string, trash bool) (RecordSlice, error) {
+	q := s.db.RecordQuery(dep.Service.Name).WithContext(ctx).AndWhere(dbx.HashExp{dep.Key: id})
+	if !trash && dep.Service.SoftDelete() {
+		q.AndWhere(trashExp(false))
+	}
+	records := RecordSlice{}
+	err := q.All(&records)
+	return records, err
+}
+
+// applyDependents applies the OnDelete policies to the dependents of the
+// record id, soft telling whether it's moved to the trash. Removing a
+// record for good applies them to the trashed dependents too, which are
+// purged on cascade.
+func (s Service) applyDependents(ctx context.Context, id string, soft bool) error {
+	for _, dep := range s.dependents {
+		if !dep.applies(soft) {
+			continue
+		}
+		records, err := s.dependentsOf(ctx, dep, id, !soft)
+		if err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		svc := dep.Service.WithDao(s.db)
+		for _, r := range records {
+			switch {
+			case dep.OnDelete == Restrict:
+				return RestrictError{Label: dep.label(), Count: len(records)}
+			case dep.OnDelete == SetNull:
+				err = svc.setNull(ctx, r, dep.Key)
+			case r.GetString(DeletedField) != "":
+				err = svc.purge(ctx, r)
+			default:
+				err = svc.Delete(ctx, r.Id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setNull clears the relation field key of r. The field being optional,
+// the service rules are not checked.
+func (s Service) setNull(ctx context.Context, r Record, key string) error {
+	old := r.OriginalCopy()
+	r.Set(key, nil)
+	return s.write(ctx, &RecordEvent{Op: OpUpdate, Old: old, New: r}, func(tx Service) error {
+		return tx.db.SaveRecord(r)
+	})
+}
@@ -0,0 +1,108 @@
+//go:build !goexperiment.jsonv2
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// withCascade makes the teams take their players along, and the players
+// their scores.
+func withCascade(f fixture) fixture {
+	f.players = f.players.WithDependents(Dependent{Service: f.scores, Key: "player", OnDelete: Cascade})
+	f.teams = f.teams.WithDependents(Dependent{Service: f.players, Key: "team", OnDelete: Cascade})
+	return f
+}
+
+func trashed(t *testing.T, f fixture, collection, id string) bool {
+	t.Helper()
+	r, err := f.dao.FindRecordById(collection, id)
+	if err != nil {
+		t.Fatalf("%s %s: %v", collection, id, err)
+	}
+	return r.GetString(DeletedField) != ""
+}
+
+func TestCascadeDelete(t *testing.T) {
+	f := withCascade(newFixture(t))
+	ctx := context.Background()
+	team := create(t, f.teams, Request{"name": "Lions"})
+	player := create(t, f.players, Request{"name": "ann", "team": team.Id})
+	create(t, f.scores, Request{"player": player.Id, "points": 3})
+
+	affected, err := f.teams.Affected(ctx, team.Id)
+	if err != nil || len(affected) != 1 || affected[0].Label != "players" || affected[0].Count != 1 {
+		t.Fatalf("affected %v, %v, want one player", affected, err)
+	}
+	if err := f.teams.Delete(ctx, team.Id); err != nil {
+		t.Fatal(err)
+	}
+	if !trashed(t, f, "players", player.Id) {
+		t.Error("player not trashed along with its team")
+	}
+	if n := count(t, f.dao, "scores"); n != 1 {
+		t.Errorf("%d scores, want them kept until the player is purged", n)
+	}
+	if err := f.teams.Purge(ctx, team.Id); err != nil {
+		t.Fatal(err)
+	}
+	if n := count(t, f.dao, "players") + count(t, f.dao, "scores"); n != 0 {
+		t.Errorf("%d dependents left after purge", n)
+	}
+}
+
+func TestCascadeRestrict(t *testing.T) {
+	f := newFixture(t)
+	f.teams = f.teams.WithDependents(Dependent{Service: f.players, Key: "team", OnDelete: Restrict})
+	team := create(t, f.teams, Request{"name": "Lions"})
+	create(t, f.players, Request{"name": "ann", "team": team.Id})
+	err := f.teams.Delete(context.Background(), team.Id)
+	if !errors.Is(err, ErrRestricted) {
+		t.Fatalf("got %v, want ErrRestricted", err)
+	}
+	if trashed(t, f, "teams", team.Id) {
+		t.Error("restricted team trashed")
+	}
+}
+
+func TestCascadeRestore(t *testing.T) {
+	f := withCascade(newFixture(t))
+	ctx := context.Background()
+	team := create(t, f.teams, Request{"name": "Lions"})
+	ann := create(t, f.players, Request{"name": "ann", "team": team.Id})
+	bob := create(t, f.players, Request{"name": "bob", "team": team.Id})
+
+	// bob was trashed on his own before the team
+	if err := f.players.Delete(ctx, bob.Id); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if err := f.teams.Delete(ctx, team.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	err := f.players.Restore(ctx, ann.Id)
+	var perr ParentTrashedError
+	if !errors.As(err, &perr) || perr.Field != "team" {
+		t.Fatalf("got %v, want the player kept in the trash with its team", err)
+	}
+	if !trashed(t, f, "players", ann.Id) {
+		t.Fatal("player restored in a trashed team")
+	}
+
+	if err := f.teams.Restore(ctx, team.Id); err != nil {
+		t.Fatal(err)
+	}
+	if trashed(t, f, "teams", team.Id) || trashed(t, f, "players", ann.Id) {
+		t.Error("team restored without the player trashed along with it")
+	}
+	if !trashed(t, f, "players", bob.Id) {
+		t.Error("player trashed before the team restored with it")
+	}
+	if err := f.players.Restore(ctx, bob.Id); err != nil {
+		t.Fatalf("restoring a player of a live team: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// HistoryCollection holds a record per create, update and delete of the
+// tracked services.
+const HistoryCollection = "record_history"
+
+// Change is the old and new values of a field. Old is nil on create and New
+// is nil on delete.
+type Change struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// Diff is the changes of a record, by field.
+type Diff map[string]Change
+
+// Scope returns the scope of a record, i.e. the id of the group it belongs
+// to, under which its history is listed.
+type Scope func(r Record) string
+
+// History writes the changes made through the tracked services in
+// HistoryCollection.
+type History struct {
+	db *daos.Dao
+}
+
+// NewHistory returns the history of db, creating HistoryCollection when
+// missing.
+func NewHistory(db *daos.Dao) History {
+	h := History{db: db}
+	if _, err := db.FindCollectionByNameOrId(HistoryCollection); err != nil {
+		if err = h.createCollection(); err != nil {
+			xlog.Error("error while creating the history collection", "error", err)
+		}
+	}
+	return h
+}
+
+func (h History) createCollection() error {
+	one := 1
+	actor := &schema.SchemaField{Name: "actor", Type: schema.FieldTypeText}
+	if users, err := h.db.FindCollectionByNameOrId("users"); err == nil {
+		actor = &schema.SchemaField{
+			Name:    "actor",
+			Type:    schema.FieldTypeRelation,
+			Options: &schema.RelationOptions{CollectionId: users.Id, MaxSelect: &one},
+		}
+	}
+	return h.db.SaveCollection(&models.Collection{
+		Name: HistoryCollection,
+		Type: models.CollectionTypeBase,
+		Schema: schema.NewSchema(
+			&schema.SchemaField{Name: "collection", Type: schema.FieldTypeText, Required: true},
+			&schema.SchemaField{Name: "record", Type: schema.FieldTypeText, Required: true},
+			&schema.SchemaField{Name: "scope", Type: schema.FieldTypeText},
+			&schema.SchemaField{Name: "op", Type: schema.FieldTypeText, Required: true},
+			actor,
+			&schema.SchemaField{Name: "diff", Type: schema.FieldTypeJson, Options: &schema.JsonOptions{MaxSize: 2 << 20}},
+		),
+		Indexes: types.JsonArray[string]{
+			"CREATE INDEX idx_record_history_scope ON " + HistoryCollection + " (scope, created)",
+		},
+	})
+}
+
+// Track records the changes made through svcs, in the transaction of the
+// operation. scope tells under which scope a record history is listed.
+func (h History) Track(scope Scope, svcs ...Service) {
+	write := func(e *RecordEvent) error {
+		return h.write(e, scope)
+	}
+	for _, svc := range svcs {
+		svc.OnAfterCreate().Add(write)
+		svc.OnAfterUpdate().Add(write)
+		svc.OnAfterDelete().Add(write)
+	}
+}
+
+func (h History) write(e *RecordEvent, scope Scope) error {
+	new := e.New
+	if e.Soft {
+		// the trashed record is kept, only its DeletedField changed
+		trashed, err := e.Dao.FindRecordById(e.Old.Collection().Id, e.Old.Id)
+		if err != nil {
+			return err
+		}
+		new = trashed
+	}
+	diff := diffRecords(e.Old, new)
+	if e.Op == OpUpdate && len(diff) == 0 {
+		return nil
+	}
+	op := string(e.Op)
+	if e.Soft {
+		op = "trash"
+	}
+	if e.Op == OpUpdate && e.Old.GetString(DeletedField) != "" && e.New.GetString(DeletedField) == "" {
+		op = "restore"
+	}
+	collection, err := e.Dao.FindCollectionByNameOrId(HistoryCollection)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	r := e.Record()
+	entry := models.NewRecord(collection)
+	entry.Load(map[string]any{
+		"collection": r.Collection().Name,
+		"record":     r.Id,
+		"scope":      scope(r),
+		"op":         op,
+		"actor":      e.Actor,
+		"diff":       types.JsonRaw(b),
+	})
+	return e.Dao.SaveRecord(entry)
+}
+
+// diffRecords returns the schema fields whose value differs between old
+// and new, either being possibly nil.
+func diffRecords(old, new Record) Diff {
+	diff := Diff{}
+	r := new
+	if r == nil {
+		r = old
+	}
+	if r == nil {
+		return diff
+	}
+	for _, f := range r.Collection().Schema.Fields() {
+		var o, n any
+		if old != nil {
+			o = old.Get(f.Name)
+		}
+		if new != nil {
+			n = new.Get(f.Name)
+		}
+		if !equalValues(o, n) {
+			diff[f.Name] = Change{Old: o, New: n}
+		}
+	}
+	return diff
+}
+
+// equalValues compares the json encodings of a and b, the way the values
+// are stored.
+func equalValues(a, b any) bool {
+	ja, erra := json.Marshal(a)
+	jb, errb := json.Marshal(b)
+	if erra != nil || errb != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+
+	"github.com/pocketbase/pocketbase/daos"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// Op is the write operation a RecordEvent is triggered for.
+type Op string
+
+const (
+	OpCreate Op = "create"
+	OpUpdate Op = "update"
+	OpDelete Op = "delete"
+)
+
+// RecordEvent is the payload of the service hooks.
+type RecordEvent struct {
+	Context context.Context
+	Op      Op
+	// Old is the record before the operation, nil on create.
+	Old Record
+	// New is the record being saved, nil on delete. Before-hooks may
+	// change it.
+	New Record
+	// Soft is set when a delete moves the record to the trash.
+	Soft bool
+	// Actor is the id of the user running the operation, see WithActor.
+	Actor string
+	// Dao runs in the transaction of the operation. Hooks writing to
+	// the database should use it, i.e. with Service.WithDao, to be
+	// rolled back along with the operation.
+	Dao *daos.Dao
+}
+
+// Record returns the new record or, on delete, the old one.
+func (e *RecordEvent) Record() Record {
+	if e.New != nil {
+		return e.New
+	}
+	return e.Old
+}
+
+type hooks struct {
+	before map[Op]*hook.Hook[*RecordEvent]
+	after  map[Op]*hook.Hook[*RecordEvent]
+}
+
+func newHooks() *hooks {
+	h := &hooks{
+		before: map[Op]*hook.Hook[*RecordEvent]{},
+		after:  map[Op]*hook.Hook[*RecordEvent]{},
+	}
+	for _, op := range []Op{OpCreate, OpUpdate, OpDelete} {
+		h.before[op] = &hook.Hook[*RecordEvent]{}
+		h.after[op] = &hook.Hook[*RecordEvent]{}
+	}
+	return h
+}
+
+// OnBeforeCreate is triggered before a record is validated and created.
+// A handler returning an error vetoes the operation. The hooks are shared
+// by the copies of a service, i.e. the ones returned by WithRules.
+func (s Service) OnBeforeCreate() *hook.Hook[*RecordEvent] { return s.hooks.before[OpCreate] }
+
+// OnAfterCreate is triggered once a record is created. A handler returning
+// an error rolls the operation back.
+func (s Service) OnAfterCreate() *hook.Hook[*RecordEvent] { return s.hooks.after[OpCreate] }
+
+func (s Service) OnBeforeUpdate() *hook.Hook[*RecordEvent] { return s.hooks.before[OpUpdate] }
+func (s Service) OnAfterUpdate() *hook.Hook[*RecordEvent]  { return s.hooks.after[OpUpdate] }
+func (s Service) OnBeforeDelete() *hook.Hook[*RecordEvent] { return s.hooks.before[OpDelete] }
+func (s Service) OnAfterDelete() *hook.Hook[*RecordEvent]  { return s.hooks.after[OpDelete] }
+
+// write runs op between the before and after hooks of e.Op, in a
+// transaction. When the service already runs in one, i.e. in a bulk
+// operation, that transaction is used.
+func (s Service) write(ctx context.Context, e *RecordEvent, op func(Service) error) error {
+	e.Context, e.Actor = ctx, Actor(ctx)
+	return s.db.RunInTransaction(func(tx *daos.Dao) error {
+		e.Dao = tx
+		if err := s.hooks.before[e.Op].Trigger(e); err != nil {
+			return err
+		}
+		if err := op(s.WithDao(tx)); err != nil {
+			return err
+		}
+		return s.hooks.after[e.Op].Trigger(e)
+	})
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx holding the id of the user running the
+// service operations.
+func WithActor(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, actorKey{}, id)
+}
+
+// Actor returns the user id set by WithActor.
+func Actor(ctx context.Context) string {
+	id, _ := ctx.Value(actorKey{}).(string)
+	return id
+}
@@ -0,0 +1,142 @@
+//go:build !goexperiment.jsonv2
+
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHooksOrder(t *testing.T) {
+	f := newFixture(t)
+	ctx := WithActor(context.Background(), "user1")
+	calls := []string{}
+	record := func(name string) func(*RecordEvent) error {
+		return func(e *RecordEvent) error {
+			calls = append(calls, name+":"+string(e.Op)+":"+e.Actor)
+			return nil
+		}
+	}
+	f.teams.OnBeforeCreate().Add(record("before"))
+	f.teams.OnAfterCreate().Add(record("after"))
+	f.teams.OnBeforeUpdate().Add(record("before"))
+	f.teams.OnAfterUpdate().Add(record("after"))
+	f.teams.OnBeforeDelete().Add(record("before"))
+	f.teams.OnAfterDelete().Add(record("after"))
+
+	vd, err := f.teams.Create(ctx, Request{"name": "Lions"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.teams.Update(ctx, Request{"teamid": vd.V().Id, "name": "Tigers"})
+	f.teams.Delete(ctx, vd.V().Id)
+	want := []string{
+		"before:create:user1", "after:create:user1",
+		"before:update:user1", "after:update:user1",
+		"before:delete:user1", "after:delete:user1",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("got %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("got %v, want %v", calls, want)
+		}
+	}
+}
+
+func TestHooksShared(t *testing.T) {
+	f := newFixture(t)
+	n := 0
+	f.teams.OnAfterCreate().Add(func(*RecordEvent) error { n++; return nil })
+	// copies share the hooks of the service they come from
+	create(t, f.teams.WithRules(), Request{"name": "Lions"})
+	create(t, f.teams.WithDao(f.dao), Request{"name": "Tigers"})
+	if n != 2 {
+		t.Fatalf("hook triggered %d times, want 2", n)
+	}
+}
+
+func TestHooksBeforeChangesRecord(t *testing.T) {
+	f := newFixture(t)
+	f.teams.OnBeforeCreate().Add(func(e *RecordEvent) error {
+		e.New.Set("city", "Paris")
+		return nil
+	})
+	team := create(t, f.teams, Request{"name": "Lions"})
+	stored, _ := f.dao.FindRecordById("teams", team.Id)
+	if stored.GetString("city") != "Paris" {
+		t.Fatalf("city is %q, want the one set by the hook", stored.GetString("city"))
+	}
+}
+
+func TestHooksVeto(t *testing.T) {
+	errVeto := errors.New("veto")
+	tests := []struct {
+		name string
+		add  func(f fixture)
+	}{
+		{"before", func(f fixture) {
+			f.teams.OnBeforeCreate().Add(func(*RecordEvent) error { return errVeto })
+		}},
+		{"after", func(f fixture) {
+			f.teams.OnAfterCreate().Add(func(*RecordEvent) error { return errVeto })
+		}},
+		{"after a write in the transaction", func(f fixture) {
+			f.teams.OnAfterCreate().Add(func(e *RecordEvent) error {
+				players := f.players.WithDao(e.Dao)
+				if _, err := players.Create(e.Context, Request{"name": "joe", "team": e.New.Id}); err != nil {
+					return err
+				}
+				return errVeto
+			})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			tt.add(f)
+			_, err := f.teams.Create(context.Background(), Request{"name": "Lions"})
+			if !errors.Is(err, errVeto) {
+				t.Fatalf("got %v, want the hook error", err)
+			}
+			if n := count(t, f.dao, "teams") + count(t, f.dao, "players"); n != 0 {
+				t.Fatalf("%d records left after the veto", n)
+			}
+		})
+	}
+}
+
+func TestHooksDeleteEvent(t *testing.T) {
+	f := newFixture(t)
+	var events []RecordEvent
+	f.teams.OnAfterDelete().Add(func(e *RecordEvent) error {
+		events = append(events, *e)
+		return nil
+	})
+	f.scores.OnAfterDelete().Add(func(e *RecordEvent) error {
+		events = append(events, *e)
+		return nil
+	})
+	team := create(t, f.teams, Request{"name": "Lions"})
+	score := create(t, f.scores, Request{"points": 1})
+	ctx := context.Background()
+	f.teams.Delete(ctx, team.Id)
+	f.scores.Delete(ctx, score.Id)
+	f.teams.Purge(ctx, team.Id)
+
+	want := []struct {
+		id   string
+		soft bool
+	}{{team.Id, true}, {score.Id, false}, {team.Id, false}}
+	if len(events) != len(want) {
+		t.Fatalf("got %d delete events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Old == nil || e.Old.Id != w.id || e.Soft != w.soft || e.New != nil || e.Record().Id != w.id {
+			t.Errorf("event %d: got old %v soft %v, want %s soft %v", i, e.Old, e.Soft, w.id, w.soft)
+		}
+	}
+}
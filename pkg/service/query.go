@@ -0,0 +1,174 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/models"
+)
+
+const (
+	DefaultPerPage = 30
+	MaxPerPage     = 500
+)
+
+var fieldRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Filter operators. A plain value in Filters is matched by equality.
+type (
+	// Like matches the records whose field contains the value.
+	Like string
+	// In matches the records whose field is one of the values.
+	In []any
+	// Range matches the records whose field is between From and To
+	// included. A nil bound is left open.
+	Range struct {
+		From any
+		To   any
+	}
+)
+
+type Query struct {
+	Filters Filters
+	// Sort lists the fields to sort by, prefixed by "-" for a descending
+	// order (i.e. "-created").
+	Sort    []string
+	Page    int
+	PerPage int
+	// Trash lists the trashed records instead of the live ones.
+	Trash bool
+}
+
+func NewQuery(filters Filters, sort ...string) Query {
+	return Query{Filters: filters, Sort: sort}
+}
+
+// ParseQuery reads the page, perPage and sort (comma separated) params of
+// qs. sort is used when qs has no sort param.
+func ParseQuery(qs url.Values, sort ...string) Query {
+	q := Query{Filters: Filters{}}
+	q.Page, _ = strconv.Atoi(qs.Get("page"))
+	q.PerPage, _ = strconv.Atoi(qs.Get("perPage"))
+	for _, s := range strings.Split(qs.Get("sort"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			q.Sort = append(q.Sort, s)
+		}
+	}
+	if len(q.Sort) == 0 {
+		q.Sort = sort
+	}
+	return q
+}
+
+func (q Query) WithFilters(filters Filters) Query {
+	ff := Filters{}
+	for k, v := range q.Filters {
+		ff[k] = v
+	}
+	for k, v := range filters {
+		ff[k] = v
+	}
+	q.Filters = ff
+	return q
+}
+
+func (q Query) WithPage(page, perPage int) Query {
+	q.Page, q.PerPage = page, perPage
+	return q
+}
+
+func (q Query) WithTrash() Query {
+	q.Trash = true
+	return q
+}
+
+// Normalize bounds the page and page size of q.
+func (q Query) Normalize() Query {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PerPage < 1 {
+		q.PerPage = DefaultPerPage
+	}
+	if q.PerPage > MaxPerPage {
+		q.PerPage = MaxPerPage
+	}
+	return q
+}
+
+func (q Query) offset() int64 {
+	return int64((q.Page - 1) * q.PerPage)
+}
+
+// hasField reports whether name is a system field or a field of c.
+func hasField(c *models.Collection, name string) bool {
+	if !fieldRegex.MatchString(name) {
+		return false
+	}
+	switch name {
+	case "id", "created", "updated":
+		return true
+	}
+	return c != nil && c.Schema.GetFieldByName(name) != nil
+}
+
+func (s Service) where(filters Filters) (dbx.Expression, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	c := s.GetCollection()
+	exps := []dbx.Expression{}
+	i := 0
+	for k, v := range filters {
+		if !hasField(c, k) {
+			return nil, fmt.Errorf("unknown filter field %q", k)
+		}
+		switch v := v.(type) {
+		case Like:
+			exps = append(exps, dbx.Like(k, string(v)))
+		case In:
+			exps = append(exps, dbx.In(k, v...))
+		case Range:
+			if v.From != nil {
+				p := fmt.Sprintf("from%d", i)
+				exps = append(exps, dbx.NewExp(fmt.Sprintf("[[%s]] >= {:%s}", k, p), dbx.Params{p: v.From}))
+			}
+			if v.To != nil {
+				p := fmt.Sprintf("to%d", i)
+				exps = append(exps, dbx.NewExp(fmt.Sprintf("[[%s]] <= {:%s}", k, p), dbx.Params{p: v.To}))
+			}
+		default:
+			exps = append(exps, dbx.HashExp{k: v})
+		}
+		i++
+	}
+	if len(exps) == 0 {
+		return nil, nil
+	}
+	return dbx.And(exps...), nil
+}
+
+// orderBy turns sort into order by columns, skipping the unknown fields.
+func (s Service) orderBy(sort []string) []string {
+	cols := []string{}
+	if len(sort) == 0 {
+		return cols
+	}
+	c := s.GetCollection()
+	for _, f := range sort {
+		dir := "ASC"
+		if strings.HasPrefix(f, "-") {
+			dir = "DESC"
+		}
+		f = strings.TrimLeft(f, "+-")
+		if !hasField(c, f) {
+			continue
+		}
+		cols = append(cols, f+" "+dir)
+	}
+	return cols
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	pmodels "github.com/josuebrunel/sportdropin/pkg/models"
+	"github.com/josuebrunel/sportdropin/pkg/view"
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+)
+
+// Repo maps the records of a service collection to T, one of the pkg/models
+// structs. Expanded relations populate the Expand struct of T.
+type Repo[T any] struct {
+	svc Service
+}
+
+func NewRepo[T any](svc Service) Repo[T] {
+	return Repo[T]{svc: svc}
+}
+
+func (r Repo[T]) Service() Service { return r.svc }
+
+func (r Repo[T]) GetID() string { return r.svc.GetID() }
+
+func (r Repo[T]) Get(ctx context.Context, id string, expand ...string) (view.ViewData[T], error) {
+	return toViewData[T](r.svc.GetByID(ctx, id, expand...))
+}
+
+func (r Repo[T]) List(ctx context.Context, q Query, expand ...string) (view.ViewData[pmodels.Collection[T]], error) {
+	vd, err := r.svc.List(ctx, q, expand...)
+	page := pmodels.Collection[T]{
+		Page:       vd.V().Page,
+		PerPage:    vd.V().PerPage,
+		TotalItems: vd.V().TotalItems,
+		TotalPages: vd.V().TotalPages,
+	}
+	items, merr := ToModels[T](vd.V().Items)
+	if err == nil && merr != nil {
+		err = merr
+		vd.Errors["error"] = err
+	}
+	page.Items = items
+	return view.NewViewData(page, vd.Errors), err
+}
+
+func (r Repo[T]) Create(ctx context.Context, m T) (view.ViewData[T], error) {
+	req, err := r.request(m)
+	if err != nil {
+		return view.NewViewData(m, errorsmap.EMap{"error": err}), err
+	}
+	delete(req, "id")
+	delete(req, VersionField)
+	return toViewData[T](r.svc.Create(ctx, req))
+}
+
+// Update saves every field of m. Load the record with Get before binding
+// the changes onto it to keep the fields left out of a form.
+func (r Repo[T]) Update(ctx context.Context, m T) (view.ViewData[T], error) {
+	req, err := r.request(m)
+	if err != nil {
+		return view.NewViewData(m, errorsmap.EMap{"error": err}), err
+	}
+	req[r.svc.ID] = req["id"]
+	delete(req, "id")
+	return toViewData[T](r.svc.Update(ctx, req))
+}
+
+func (r Repo[T]) Delete(ctx context.Context, id string) error {
+	return r.svc.Delete(ctx, id)
+}
+
+// request turns m into a Request holding its id, version and the
+// collection fields.
+func (r Repo[T]) request(m T) (Request, error) {
+	data, err := ToRequest(m)
+	if err != nil {
+		return data, err
+	}
+	req := Request{}
+	if c := r.svc.GetCollection(); c != nil {
+		for _, f := range c.Schema.Fields() {
+			if v, ok := data[f.Name]; ok {
+				req[f.Name] = v
+			}
+		}
+	}
+	for _, k := range []string{"id", VersionField} {
+		if v, ok := data[k]; ok {
+			req[k] = v
+		}
+	}
+	return req, nil
+}
+
+// ToModel maps r and its expanded relations to T.
+func ToModel[T any](r Record) (T, error) {
+	var m T
+	if r == nil {
+		return m, nil
+	}
+	err := UnmarshalTo(r, &m)
+	return m, err
+}
+
+func ToModels[T any](rr RecordSlice) ([]T, error) {
+	mm := make([]T, 0, len(rr))
+	for _, r := range rr {
+		m, err := ToModel[T](r)
+		if err != nil {
+			return mm, err
+		}
+		mm = append(mm, m)
+	}
+	return mm, nil
+}
+
+// ToRequest maps m to a Request using its json field names.
+func ToRequest(m any) (Request, error) {
+	req := Request{}
+	b, err := json.Marshal(m)
+	if err != nil {
+		xlog.Error("failed to marshal model", "model", m, "error", err)
+		return req, err
+	}
+	err = json.Unmarshal(b, &req)
+	return req, err
+}
+
+func toViewData[T any](vd view.ViewData[Record], err error) (view.ViewData[T], error) {
+	m, merr := ToModel[T](vd.V())
+	if vd.Errors == nil {
+		vd.Errors = errorsmap.New()
+	}
+	if err == nil && merr != nil {
+		err = merr
+		vd.Errors["error"] = err
+	}
+	return view.NewViewData(m, vd.Errors), err
+}
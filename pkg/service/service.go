@@ -3,15 +3,16 @@ package service
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"strings"
 
 	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+	pmodels "github.com/josuebrunel/sportdropin/pkg/models"
+	"github.com/josuebrunel/sportdropin/pkg/util"
 	"github.com/josuebrunel/sportdropin/pkg/view"
 	"github.com/josuebrunel/sportdropin/pkg/xlog"
 	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase/daos"
 	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 type (
@@ -19,23 +20,28 @@ type (
 	RecordSlice = []*models.Record
 	Request     = map[string]any
 	Requests    = []Request
-	BackRel     = map[string]map[string]any
 	Filters     = map[string]any
+
+	RecordCollection = pmodels.Collection[Record]
 )
 
 type Service struct {
-	Name string
-	ID   string
-	db   *daos.Dao
+	Name       string
+	ID         string
+	db         *daos.Dao
+	rules      []Rule
+	dependents []Dependent
+	hooks      *hooks
 }
 
 func (s Service) GetID() string { return s.ID }
 
 func NewService(name, id string, db *daos.Dao) Service {
 	return Service{
-		Name: name,
-		ID:   id,
-		db:   db,
+		Name:  name,
+		ID:    id,
+		db:    db,
+		hooks: newHooks(),
 	}
 }
 
@@ -56,6 +62,14 @@ func (s Service) GetNewRecords(size int) RecordSlice {
 	return make([]*models.Record, size)
 }
 
+// save validates record against the service rules and saves it.
+func (s Service) save(record Record) error {
+	if err := s.validate(record); err != nil {
+		return err
+	}
+	return s.db.SaveRecord(record)
+}
+
 func (s Service) create(ctx context.Context, req Request) (Record, error) {
 	collection, err := s.db.FindCollectionByNameOrId(s.Name)
 	if err != nil {
@@ -66,7 +80,10 @@ func (s Service) create(ctx context.Context, req Request) (Record, error) {
 	for k, v := range req {
 		record.Set(k, v)
 	}
-	if err = s.db.SaveRecord(record); err != nil {
+	err = s.write(ctx, &RecordEvent{Op: OpCreate, New: record}, func(tx Service) error {
+		return tx.save(record)
+	})
+	if err != nil {
 		xlog.Error("error while inserting", "record", record, "error", err)
 		return s.GetNewRecord(), err
 	}
@@ -74,32 +91,31 @@ func (s Service) create(ctx context.Context, req Request) (Record, error) {
 }
 
 func (s Service) Create(ctx context.Context, req Request) (view.ViewData[Record], error) {
-	em := errorsmap.New()
 	record, err := s.create(ctx, req)
-	em["error"] = err
+	em := ErrorsMap(err)
 	if err != nil {
 		return view.NewViewData(s.GetNewRecord(), em), err
 	}
 	return view.NewViewData(record, em), nil
 }
 
-func (s Service) BulkCreate(ctx context.Context, req []Request) (view.ViewData[RecordSlice], error) {
-	em := errorsmap.New()
-	records := RecordSlice{}
-	for i, r := range req {
-		record, err := s.create(ctx, r)
-		em["error"] = err
-		if err != nil {
-			em[fmt.Sprintf("%d", i)] = err
-			continue
-		}
-		records = append(records, record)
+// BulkCreate creates a record for each request. See BulkMode for how
+// failures are handled.
+func (s Service) BulkCreate(ctx context.Context, reqs Requests, mode BulkMode) (view.ViewData[RecordSlice], error) {
+	records, em, err := s.bulk(ctx, reqs, mode, Service.create)
+	if err != nil {
+		xlog.Error("error while bulk creating", "collection", s.Name, "errors", em)
 	}
-	return view.NewViewData(records, em), nil
+	return view.NewViewData(records, em), err
 }
 
 func (s Service) getByID(ctx context.Context, id string) (Record, error) {
-	record, err := s.db.FindRecordById(s.Name, id)
+	record, err := s.db.FindRecordById(s.Name, id, func(q *dbx.SelectQuery) error {
+		if s.SoftDelete() {
+			q.AndWhere(trashExp(false))
+		}
+		return nil
+	})
 	if err != nil {
 		xlog.Error("error while getting", "record", id, "error", err)
 		return s.GetNewRecord(), err
@@ -112,28 +128,34 @@ func (s Service) GetByID(ctx context.Context, id string, expand ...string) (view
 	record, err := s.getByID(ctx, id)
 	em["error"] = err
 	if len(expand) > 0 {
-		s.db.ExpandRecord(record, expand, nil)
+		s.db.ExpandRecord(record, expand, s.expandFetch)
 	}
 	xlog.Debug("record", "record", record)
 	return view.NewViewData(record, em), err
 }
 
-func (s Service) GetByIDWithBackRel(ctx context.Context, id string, expand BackRel) (view.ViewData[Record], error) {
+func (s Service) GetByIDWithBackRel(ctx context.Context, id string, rels ...BackRel) (view.ViewData[Record], error) {
 	em := errorsmap.New()
 	record, err := s.getByID(ctx, id)
 	em["error"] = err
-	rels := map[string]any{}
-	for k, v := range expand {
-		rels[k] = s.FindRecordsByExpr(ctx, k, dbx.HashExp(v))
+	if err == nil {
+		if err = s.expandBackRels(ctx, RecordSlice{record}, rels...); err != nil {
+			xlog.Error("error while expanding back relations", "record", id, "error", err)
+			em["error"] = err
+		}
 	}
-	record.SetExpand(rels)
 	xlog.Debug("record", "record", record)
 	return view.NewViewData(record, em), err
 }
 
 func (s Service) GetByData(ctx context.Context, key string, value any) (view.ViewData[Record], error) {
 	em := errorsmap.New()
-	record, err := s.db.FindFirstRecordByData(s.Name, key, value)
+	record := s.GetNewRecord()
+	q := s.db.RecordQuery(s.Name).WithContext(ctx).AndWhere(dbx.HashExp{key: value}).Limit(1)
+	if isSoftDelete(record.Collection()) {
+		q.AndWhere(trashExp(false))
+	}
+	err := q.One(record)
 	if err != nil {
 		xlog.Error("error while getting record", "key", key, "value", value, "error", err)
 		em["error"] = err
@@ -143,71 +165,102 @@ func (s Service) GetByData(ctx context.Context, key string, value any) (view.Vie
 	return view.NewViewData(record, em), nil
 }
 
-func (s Service) list(ctx context.Context, filters map[string]any) (RecordSlice, error) {
-	hashExp := dbx.HashExp{}
-	for k, v := range filters {
-		hashExp[k] = v
+func (s Service) list(ctx context.Context, q Query) (RecordCollection, error) {
+	q = q.Normalize()
+	page := RecordCollection{Page: q.Page, PerPage: q.PerPage, Items: RecordSlice{}}
+	where, err := s.where(q.Filters)
+	if err != nil {
+		xlog.Error("error while listing records", "filters", q.Filters, "error", err)
+		return page, err
 	}
 
-	records, err := s.db.FindRecordsByExpr(s.Name, dbx.NewExp("1={:one}", dbx.Params{"one": 1}), hashExp)
+	soft := s.SoftDelete()
+	query := func() *dbx.SelectQuery {
+		query := s.db.RecordQuery(s.Name).WithContext(ctx)
+		if where != nil {
+			query.AndWhere(where)
+		}
+		if soft {
+			query.AndWhere(trashExp(q.Trash))
+		}
+		return query
+	}
+
+	if err = query().Select("count(*)").Row(&page.TotalItems); err != nil {
+		xlog.Error("error while counting records", "error", err)
+		return page, err
+	}
+	page.TotalPages = (page.TotalItems + q.PerPage - 1) / q.PerPage
+
+	err = query().
+		OrderBy(s.orderBy(q.Sort)...).
+		Limit(int64(q.PerPage)).
+		Offset(q.offset()).
+		All(&page.Items)
 	if err != nil {
 		xlog.Error("error while listing records", "error", err)
-		return records, err
+		return page, err
 	}
-	return records, nil
+	return page, nil
 }
 
-func (s Service) List(ctx context.Context, filters map[string]any, expand ...string) (view.ViewData[RecordSlice], error) {
+func (s Service) List(ctx context.Context, q Query, expand ...string) (view.ViewData[RecordCollection], error) {
 	em := errorsmap.New()
 
-	records, err := s.list(ctx, filters)
+	page, err := s.list(ctx, q)
 	if err != nil {
 		xlog.Error("error while listing records", "error", err)
 		em["error"] = err
-		return view.NewViewData[RecordSlice](nil, em), err
+		return view.NewViewData(page, em), err
 	}
 	if len(expand) > 0 {
-		s.db.ExpandRecords(records, expand, nil)
+		s.db.ExpandRecords(page.Items, expand, s.expandFetch)
 	}
-	return view.NewViewData(records, em), nil
+	return view.NewViewData(page, em), nil
 }
 
-func (s Service) ListWithBackRel(ctx context.Context, filters map[string]any, expand BackRel) (view.ViewData[RecordSlice], error) {
+// ListWithBackRel lists the records matching q and expands rels, using a
+// single query per relation.
+func (s Service) ListWithBackRel(ctx context.Context, q Query, rels ...BackRel) (view.ViewData[RecordCollection], error) {
 	em := errorsmap.New()
-	records, err := s.list(ctx, filters)
+	page, err := s.list(ctx, q)
 	if err != nil {
 		xlog.Error("error while listing records", "error", err)
 		em["error"] = err
-		return view.NewViewData[RecordSlice](records, em), err
-	}
-	for _, record := range records {
-		rels := map[string]any{}
-		for rel, ff := range expand {
-			exp := dbx.HashExp{}
-			for k, v := range ff {
-				if strings.HasPrefix(v.(string), ":") {
-					exp[k] = record.GetId()
-				} else {
-					exp[k] = v
-				}
-			}
-			rels[rel] = s.FindRecordsByExpr(ctx, rel, exp)
-		}
-		record.SetExpand(rels)
+		return view.NewViewData(page, em), err
 	}
-	xlog.Debug("records list", "records", records)
-	return view.NewViewData(records, em), nil
+	if err = s.expandBackRels(ctx, page.Items, rels...); err != nil {
+		xlog.Error("error while expanding back relations", "error", err)
+		em["error"] = err
+		return view.NewViewData(page, em), err
+	}
+	xlog.Debug("records list", "records", page.Items)
+	return view.NewViewData(page, em), nil
 }
 
 func (s Service) upsert(ctx context.Context, req Request) (Record, error) {
-	record, err := s.db.FindRecordById(s.Name, req["id"].(string))
+	req, version := splitVersion(req)
+	e := &RecordEvent{Op: OpUpdate}
+	// a trashed record isn't updated: creating it again fails on its id
+	record, err := s.getByID(ctx, util.AssertType[string](req["id"]))
 	if err != nil {
-		xlog.Error("error while getting", "record", req["id"], "error", err)
 		record = s.GetNewRecord()
+		e.Op = OpCreate
+	} else {
+		e.Old = record.OriginalCopy()
 	}
 
 	record.Load(req)
-	if err = s.db.SaveRecord(record); err != nil {
+	e.New = record
+	err = s.write(ctx, e, func(tx Service) error {
+		if e.Old != nil {
+			if err := tx.checkVersion(record.Id, version); err != nil {
+				return err
+			}
+		}
+		return tx.save(record)
+	})
+	if err != nil {
 		xlog.Error("error while updating", "record", record, "error", err)
 		return record, err
 	}
@@ -215,39 +268,31 @@ func (s Service) upsert(ctx context.Context, req Request) (Record, error) {
 }
 
 func (s Service) Upsert(ctx context.Context, req Request) (view.ViewData[Record], error) {
-	em := errorsmap.New()
 	record, err := s.upsert(ctx, req)
-	if err != nil {
-		em["error"] = err
-	}
-	return view.NewViewData(record, em), err
+	return view.NewViewData(record, ErrorsMap(err)), err
 }
 
-func (s Service) BulkUpsert(ctx context.Context, reqs Requests) (view.ViewData[RecordSlice], error) {
-	em := errorsmap.New()
-	records := RecordSlice{}
-	for i, r := range reqs {
-		record, err := s.upsert(ctx, r)
-		em["error"] = err
-		if err != nil {
-			em[fmt.Sprintf("%d", i)] = err
-			continue
-		}
-		records = append(records, record)
+// BulkUpsert creates or updates a record for each request. See BulkMode
+// for how failures are handled.
+func (s Service) BulkUpsert(ctx context.Context, reqs Requests, mode BulkMode) (view.ViewData[RecordSlice], error) {
+	records, em, err := s.bulk(ctx, reqs, mode, Service.upsert)
+	if err != nil {
+		xlog.Error("error while bulk upserting", "collection", s.Name, "errors", em)
 	}
-	return view.NewViewData(records, em), nil
+	return view.NewViewData(records, em), err
 }
 
 func (s Service) Update(ctx context.Context, req Request) (view.ViewData[Record], error) {
 	em := errorsmap.New()
 
-	record, err := s.db.FindFirstRecordByData(s.Name, "id", req[s.ID])
+	record, err := s.getByID(ctx, util.AssertType[string](req[s.ID]))
 	if err != nil {
-		xlog.Error("error while getting", "record", req[s.ID], "error", err)
 		em["error"] = err
 		return view.NewViewData(s.GetNewRecord(), em), err
 	}
 
+	old := record.OriginalCopy()
+	req, version := splitVersion(req)
 	for k, v := range req {
 		if k == s.ID {
 			continue
@@ -255,26 +300,52 @@ func (s Service) Update(ctx context.Context, req Request) (view.ViewData[Record]
 		record.Set(k, v)
 	}
 
-	if err = s.db.SaveRecord(record); err != nil {
+	err = s.write(ctx, &RecordEvent{Op: OpUpdate, Old: old, New: record}, func(tx Service) error {
+		if err := tx.checkVersion(record.Id, version); err != nil {
+			return err
+		}
+		return tx.save(record)
+	})
+	if err != nil {
 		xlog.Error("error while updating", "record", record, "error", err)
-		em["error"] = err
-		return view.NewViewData(s.GetNewRecord(), em), err
+		return view.NewViewData(s.GetNewRecord(), ErrorsMap(err)), err
 	}
 
 	return view.NewViewData(record, em), err
 }
 
+// Delete moves the record id to the trash when the collection has a
+// DeletedField and removes it otherwise, applying the policies of the
+// service dependents first.
 func (s Service) Delete(ctx context.Context, id string) error {
 	em := errorsmap.New()
 
-	record, err := s.db.FindFirstRecordByData(s.Name, "id", id)
+	record, err := s.getByID(ctx, id)
 	if err != nil {
-		xlog.Error("error while getting", "record", id, "error", err)
 		em["error"] = err
 		return err
 	}
 
-	if err = s.db.DeleteRecord(record); err != nil {
+	if isSoftDelete(record.Collection()) {
+		var at types.DateTime
+		ctx, at = trashedAt(ctx)
+		trashed := record.CleanCopy()
+		trashed.Set(DeletedField, at)
+		err = s.write(ctx, &RecordEvent{Op: OpDelete, Old: record, Soft: true}, func(tx Service) error {
+			if err := tx.applyDependents(ctx, record.Id, true); err != nil {
+				return err
+			}
+			return tx.db.SaveRecord(trashed)
+		})
+	} else {
+		err = s.write(ctx, &RecordEvent{Op: OpDelete, Old: record}, func(tx Service) error {
+			if err := tx.applyDependents(ctx, record.Id, false); err != nil {
+				return err
+			}
+			return tx.db.DeleteRecord(record)
+		})
+	}
+	if err != nil {
 		xlog.Error("error while deleting", "record", record, "error", err)
 		em["error"] = err
 		return err
@@ -302,5 +373,5 @@ func UnmarshalTo(r Record, m any) error {
 	if err = json.Unmarshal(b, m); err != nil {
 		xlog.Error("failed to unmarshal record to target", "collection", r.Collection(), "id", r.GetId())
 	}
-	return nil
+	return err
 }
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/josuebrunel/sportdropin/pkg/xlog"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/models"
+	"github.com/pocketbase/pocketbase/models/schema"
+	"github.com/pocketbase/pocketbase/tools/list"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// DeletedField is the date field of the collections whose records are
+// moved to the trash on delete rather than removed. Trashed records are
+// left out of Get, List and expands.
+const DeletedField = "deleted"
+
+var ErrNotTrashed = errors.New("record is not in the trash")
+
+// ParentTrashedError is returned by Restore when a record the restored one
+// relates to through Field is in the trash.
+type ParentTrashedError struct {
+	Field string
+}
+
+func (e ParentTrashedError) Error() string {
+	return fmt.Sprintf("its %s is in the trash, restore it first", e.Field)
+}
+
+type trashedAtKey struct{}
+
+// trashedAt returns the date of the records moved to the trash with ctx
+// and a ctx holding it: the records a delete cascades to get the date of
+// the deleted one, so they can be restored along.
+func trashedAt(ctx context.Context) (context.Context, types.DateTime) {
+	if at, ok := ctx.Value(trashedAtKey{}).(types.DateTime); ok {
+		return ctx, at
+	}
+	at := types.NowDateTime()
+	return context.WithValue(ctx, trashedAtKey{}, at), at
+}
+
+func isSoftDelete(c *models.Collection) bool {
+	return c != nil && c.Schema.GetFieldByName(DeletedField) != nil
+}
+
+// SoftDelete reports whether Delete moves the records to the trash.
+func (s Service) SoftDelete() bool {
+	return isSoftDelete(s.GetCollection())
+}
+
+// trashExp matches the trashed records, or the live ones when trashed is
+// false.
+func trashExp(trashed bool) dbx.Expression {
+	if trashed {
+		return dbx.NewExp("[[" + DeletedField + "]] != ''")
+	}
+	return Live(DeletedField)
+}
+
+// Live matches the records left out of the trash, column being their
+// DeletedField, for the queries made outside of a Service.
+func Live(column string) dbx.Expression {
+	return dbx.Or(dbx.HashExp{column: ""}, dbx.HashExp{column: nil})
+}
+
+// expandFetch fetches the expanded records, leaving out the trashed ones.
+func (s Service) expandFetch(c *models.Collection, ids []string) (RecordSlice, error) {
+	return s.db.FindRecordsByIds(c.Id, ids, func(q *dbx.SelectQuery) error {
+		if isSoftDelete(c) {
+			q.AndWhere(trashExp(false))
+		}
+		return nil
+	})
+}
+
+func (s Service) getTrashed(ctx context.Context, id string) (Record, error) {
+	record := s.GetNewRecord()
+	err := s.db.RecordQuery(s.Name).
+		WithContext(ctx).
+		AndWhere(dbx.HashExp{"id": id}).
+		AndWhere(trashExp(true)).
+		Limit(1).
+		One(record)
+	if err != nil {
+		xlog.Error("error while getting trashed record", "record", id, "error", err)
+		return s.GetNewRecord(), ErrNotTrashed
+	}
+	return record, nil
+}
+
+// Restore moves the record id out of the trash, along with the Cascade
+// dependents trashed with it. It fails with a ParentTrashedError while a
+// record it relates to is in the trash.
+func (s Service) Restore(ctx context.Context, id string) error {
+	record, err := s.getTrashed(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.restore(ctx, record)
+}
+
+func (s Service) restore(ctx context.Context, record Record) error {
+	if err := s.checkParents(ctx, record); err != nil {
+		return err
+	}
+	old := record.OriginalCopy()
+	at := record.GetString(DeletedField)
+	record.Set(DeletedField, "")
+	return s.write(ctx, &RecordEvent{Op: OpUpdate, Old: old, New: record}, func(tx Service) error {
+		if err := tx.db.SaveRecord(record); err != nil {
+			return err
+		}
+		return tx.restoreDependents(ctx, record.Id, at)
+	})
+}
+
+// checkParents returns a ParentTrashedError when a record r relates to is
+// in the trash.
+func (s Service) checkParents(ctx context.Context, r Record) error {
+	for _, f := range r.Collection().Schema.Fields() {
+		opts, ok := f.Options.(*schema.RelationOptions)
+		ids := r.GetStringSlice(f.Name)
+		if !ok || len(ids) == 0 {
+			continue
+		}
+		c, err := s.db.FindCollectionByNameOrId(opts.CollectionId)
+		if err != nil {
+			return err
+		}
+		if !isSoftDelete(c) {
+			continue
+		}
+		n := 0
+		err = s.db.RecordQuery(c).
+			WithContext(ctx).
+			Select("count(*)").
+			AndWhere(dbx.In("id", list.ToInterfaceSlice(ids)...)).
+			AndWhere(trashExp(true)).
+			Row(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ParentTrashedError{Field: f.Name}
+		}
+	}
+	return nil
+}
+
+// restoreDependents restores the Cascade dependents of the record id
+// trashed at the date at, i.e. along with it. The ones trashed before are
+// left in the trash.
+func (s Service) restoreDependents(ctx context.Context, id, at string) error {
+	for _, dep := range s.dependents {
+		if dep.OnDelete != Cascade || !dep.Service.SoftDelete() {
+			continue
+		}
+		records := RecordSlice{}
+		err := s.db.RecordQuery(dep.Service.Name).
+			WithContext(ctx).
+			AndWhere(dbx.HashExp{dep.Key: id, DeletedField: at}).
+			All(&records)
+		if err != nil {
+			return err
+		}
+		svc := dep.Service.WithDao(s.db)
+		for _, r := range records {
+			err := svc.restore(ctx, r)
+			// a dependent trashed with another of its parents stays there
+			if errors.As(err, &ParentTrashedError{}) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Purge removes the trashed record id for good.
+func (s Service) Purge(ctx context.Context, id string) error {
+	record, err := s.getTrashed(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.purge(ctx, record)
+}
+
+func (s Service) purge(ctx context.Context, record Record) error {
+	return s.write(ctx, &RecordEvent{Op: OpDelete, Old: record}, func(tx Service) error {
+		if err := tx.applyDependents(ctx, record.Id, false); err != nil {
+			return err
+		}
+		return tx.db.DeleteRecord(record)
+	})
+}
+
+// PurgeTrash removes the records trashed before t and returns how many
+// were removed.
+func (s Service) PurgeTrash(ctx context.Context, t time.Time) (int, error) {
+	if !s.SoftDelete() {
+		return 0, nil
+	}
+	before, err := types.ParseDateTime(t)
+	if err != nil {
+		return 0, err
+	}
+	records := RecordSlice{}
+	err = s.db.RecordQuery(s.Name).
+		WithContext(ctx).
+		AndWhere(trashExp(true)).
+		AndWhere(dbx.NewExp("[["+DeletedField+"]] < {:before}", dbx.Params{"before": before.String()})).
+		All(&records)
+	if err != nil {
+		return 0, err
+	}
+	n, errs := 0, []error{}
+	for _, r := range records {
+		if err = s.purge(ctx, r); err != nil {
+			xlog.Error("error while purging", "collection", s.Name, "record", r.Id, "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
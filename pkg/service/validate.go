@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/josuebrunel/sportdropin/pkg/errorsmap"
+)
+
+var ErrValidation = errors.New("some fields are invalid")
+
+// Rule checks a record before it is saved. It returns nil or the
+// validation.Errors found, keyed by field name.
+type Rule func(r Record) error
+
+// WithRules returns a copy of the service validating its records against
+// rules on create, update and upsert.
+func (s Service) WithRules(rules ...Rule) Service {
+	s.rules = append(append([]Rule{}, s.rules...), rules...)
+	return s
+}
+
+func (s Service) validate(r Record) error {
+	errs := validation.Errors{}
+	for _, rule := range s.rules {
+		err := rule(r)
+		if err == nil {
+			continue
+		}
+		var verrs validation.Errors
+		if !errors.As(err, &verrs) {
+			return err
+		}
+		for k, v := range verrs {
+			if _, ok := errs[k]; !ok && v != nil {
+				errs[k] = v
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Field validates the value of field with ozzo-validation rules, the ones
+// PocketBase itself uses.
+func Field(field string, rules ...validation.Rule) Rule {
+	return func(r Record) error {
+		if err := validation.Validate(r.Get(field), rules...); err != nil {
+			return validation.Errors{field: err}
+		}
+		return nil
+	}
+}
+
+// DateOrder checks that the date field from is not after the date field to.
+func DateOrder(from, to string) Rule {
+	return func(r Record) error {
+		f, t := r.GetDateTime(from), r.GetDateTime(to)
+		if f.IsZero() || t.IsZero() || !f.Time().After(t.Time()) {
+			return nil
+		}
+		return validation.Errors{to: fmt.Errorf("must not be before %s", from)}
+	}
+}
+
+// Number validates a number or a numeric string between min and max.
+func Number(min, max float64) validation.Rule {
+	return validation.By(func(value any) error {
+		var v float64
+		switch value := value.(type) {
+		case nil:
+			return nil
+		case float64:
+			v = value
+		case int:
+			v = float64(value)
+		case string:
+			if value == "" {
+				return nil
+			}
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return errors.New("must be a number")
+			}
+			v = f
+		default:
+			return errors.New("must be a number")
+		}
+		if v < min || v > max {
+			return fmt.Errorf("must be between %v and %v", min, max)
+		}
+		return nil
+	})
+}
+
+// Values validates each value of the json object field with rules. Errors
+// are keyed <field>.<key>.
+func Values(field string, rules ...validation.Rule) Rule {
+	return func(r Record) error {
+		values := map[string]any{}
+		if err := json.Unmarshal([]byte(r.GetString(field)), &values); err != nil {
+			if r.GetString(field) == "" {
+				return nil
+			}
+			return validation.Errors{field: errors.New("must be a json object")}
+		}
+		errs := validation.Errors{}
+		for k, v := range values {
+			if err := validation.Validate(v, rules...); err != nil {
+				errs[field+"."+k] = err
+			}
+		}
+		if len(errs) == 0 {
+			return nil
+		}
+		return errs
+	}
+}
+
+// ErrorsMap turns err into an errorsmap.EMap. The validation errors, ours or
+// the PocketBase ones, are set under their field name and "error" holds
+// ErrValidation.
+func ErrorsMap(err error) errorsmap.EMap {
+	em := errorsmap.New()
+	if err == nil {
+		return em
+	}
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) {
+		em["error"] = err
+		return em
+	}
+	for k, v := range verrs {
+		em[k] = v
+	}
+	em["error"] = ErrValidation
+	return em
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// VersionField is the request field holding the updated date of the record
+// the changes were made on. Update, Upsert and BulkUpsert reject the
+// changes with ErrConflict when the record was saved since.
+const VersionField = "updated"
+
+var ErrConflict = errors.New("this record changed, review and retry")
+
+// splitVersion returns a copy of req without its version and the version.
+func splitVersion(req Request) (Request, string) {
+	changes := Request{}
+	for k, v := range req {
+		if k != VersionField {
+			changes[k] = v
+		}
+	}
+	switch v := req[VersionField].(type) {
+	case string:
+		return changes, v
+	case types.DateTime:
+		return changes, v.String()
+	}
+	return changes, ""
+}
+
+// checkVersion returns ErrConflict when the stored record id was updated
+// at another date than version. An empty version is not checked.
+func (s Service) checkVersion(id, version string) error {
+	if version == "" || id == "" {
+		return nil
+	}
+	expected, err := types.ParseDateTime(version)
+	if err != nil {
+		return ErrConflict
+	}
+	current, err := s.db.FindRecordById(s.Name, id)
+	if err != nil {
+		return err
+	}
+	if !current.Updated.Time().Equal(expected.Time()) {
+		return ErrConflict
+	}
+	return nil
+}
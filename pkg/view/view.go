@@ -53,6 +53,14 @@ func Render(ctx echo.Context, status int, tpl templ.Component, data any) error {
 	return nil
 }
 
+// Retarget makes htmx swap the response into target, a css selector
+// relative to the requesting element, using swap instead of the request
+// target. It must be called before rendering.
+func Retarget(ctx echo.Context, target, swap string) {
+	ctx.Response().Header().Set("HX-Retarget", target)
+	ctx.Response().Header().Set("HX-Reswap", swap)
+}
+
 func Get[T any](ctx context.Context, key string) T {
 	cx := ctx.Value(xc).(map[string]any)
 	var r T
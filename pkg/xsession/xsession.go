@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/alexedwards/scs/v2"
+	"github.com/josuebrunel/sportdropin/pkg/pbclient"
 	"github.com/labstack/echo/v5"
 	"github.com/labstack/echo/v5/middleware"
 )
@@ -162,6 +163,21 @@ func DeleteUser(c context.Context) {
 	Delete(c, SessionName)
 }
 
+// AuthStore returns a pbclient auth store loaded from the session user.
+// Refreshed tokens are saved back into the session.
+func AuthStore(c context.Context) *pbclient.AuthStore {
+	u := GetUser(c)
+	store := pbclient.NewAuthStore(u.Token, pbclient.UserRecord{ID: u.ID, Email: u.Email})
+	store.OnChange = func(token string, record pbclient.UserRecord) {
+		if token == "" {
+			DeleteUser(c)
+			return
+		}
+		SetUser(c, XUser{ID: record.ID, Token: token, Email: record.Email})
+	}
+	return store
+}
+
 func IsAuthenticated(c context.Context) bool {
 	return !(GetUser(c) == (XUser{}))
 }